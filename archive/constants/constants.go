@@ -38,7 +38,8 @@ const (
 	DEFAULT_SERVICE_NUMBER          = 0
 )
 
-// Constants for the operations
+// Constants for the operations. The operations 1 to 6 are the ones of the
+// Archive Service of the COM specification (CCSDS 521.1-B-1)
 const (
 	OPERATION_IDENTIFIER_RETRIEVE = iota + 1
 	OPERATION_IDENTIFIER_QUERY
@@ -46,6 +47,18 @@ const (
 	OPERATION_IDENTIFIER_STORE
 	OPERATION_IDENTIFIER_UPDATE
 	OPERATION_IDENTIFIER_DELETE
+	// The standing queries (7 and 8) are a local extension of the service,
+	// they aren't in the specification: another implementation of the
+	// Archive Service doesn't know them and may use these numbers otherwise
+	OPERATION_IDENTIFIER_STANDING_QUERY
+	OPERATION_IDENTIFIER_CANCEL_STANDING_QUERY
+)
+
+// Constants for the events published by the Archive Service
+const (
+	ARCHIVE_SERVICE_EVENT_OBJECT_STORED = iota + 1
+	ARCHIVE_SERVICE_EVENT_OBJECT_UPDATED
+	ARCHIVE_SERVICE_EVENT_OBJECT_DELETED
 )
 
 // Constants for all the errors
@@ -64,9 +77,23 @@ const (
 	ARCHIVE_SERVICE_QUERY_LISTS_SIZE_ERROR                      String = "The size of the two lists must be the same"
 	ARCHIVE_SERVICE_QUERY_SORT_FIELD_NAME_INVALID_ERROR         String = "SortFieldName parameter doesn't reference a defined field"
 	ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR                    String = "QueryFilter contains an error"
+	ARCHIVE_SERVICE_QUERY_DOMAIN_NULL_IDENTIFIER_ERROR          String = "The domain of an ArchiveQuery must not contain a NULL identifier"
 	ARCHIVE_SERVICE_UNKNOWN_ELEMENT                             String = "Unknown element, cannot find it in the archive"
+	ARCHIVE_SERVICE_UNKNOWN_STANDING_QUERY                      String = "Unknown standing query"
+	ARCHIVE_SERVICE_STANDING_QUERY_OVERFLOW_ERROR               String = "The standing query can't keep up with the changes of the archive, objects have been dropped"
+	ARCHIVE_SERVICE_CONSUMER_CANCELLED_ERROR                    String = "The operation has been cancelled"
+	ARCHIVE_SERVICE_CONSUMER_TIMEOUT_ERROR                      String = "The operation has timed out"
 )
 
 const (
 	LENGTH = 16394
+	// DEFAULT_MAX_OBJECTS_PER_UPDATE is the default maximum number of
+	// objects sent in each message of the Query operation to a consumer
+	// which asks for indexed updates (0 means no limit). The groups sent
+	// to the other consumers aren't split
+	DEFAULT_MAX_OBJECTS_PER_UPDATE = 1000
+	// DEFAULT_MAX_QUEUED_CHANGES is the default maximum number of changes
+	// queued for each hook of the archive, the changes which don't fit in
+	// the queue of a hook are dropped
+	DEFAULT_MAX_QUEUED_CHANGES = 1024
 )
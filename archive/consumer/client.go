@@ -0,0 +1,212 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package consumer
+
+import (
+	"context"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+	. "github.com/ccsdsmo/malgo/mal/api"
+	. "github.com/ccsdsmo/malgo/mal/encoding/binary"
+
+	. "github.com/etiennelndr/archiveservice/archive/constants"
+	. "github.com/etiennelndr/archiveservice/data"
+	. "github.com/etiennelndr/archiveservice/errors"
+)
+
+// ArchiveClient : A consumer of the Archive Service which keeps a single
+// context open between its operations, instead of creating one for each
+// operation. It is safe for concurrent use, several operations can be
+// called at the same time from the same URL. Each operation has its own
+// client context, so that it can be aborted without the other ones
+type ArchiveClient struct {
+	ctx         *Context
+	providerURI *URI
+	factory     EncodingFactory
+}
+
+// NewArchiveClient : Create a client of the Archive Service provider, its
+// context listens to url until the client is closed
+func NewArchiveClient(url string, providerURI *URI) (*ArchiveClient, error) {
+	ctx, err := NewContext(url)
+	if err != nil {
+		return nil, err
+	}
+
+	factory := new(FixedBinaryEncoding)
+
+	client := &ArchiveClient{ctx, providerURI, factory}
+
+	return client, nil
+}
+
+// Close : Close the context of the client
+func (client *ArchiveClient) Close() {
+	client.ctx.Close()
+}
+
+//======================================================================//
+//								OPERATIONS								//
+//======================================================================//
+// Retrieve : Retrieve objects from the archive
+func (client *ArchiveClient) Retrieve(ctx context.Context, objectType ObjectType, identifierList IdentifierList, longList LongList) (*ArchiveDetailsList, ElementList, *ServiceError, error) {
+	octx, err := client.newOperationContext()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	op := octx.cctx.NewInvokeOperation(client.providerURI,
+		COM_AREA_NUMBER,
+		COM_AREA_VERSION,
+		ARCHIVE_SERVICE_SERVICE_NUMBER,
+		OPERATION_IDENTIFIER_RETRIEVE)
+	consumer := &InvokeConsumer{octx, op, client.factory}
+	defer consumer.Close()
+
+	// Call Invoke operation
+	errorsList, err := consumer.retrieveInvoke(ctx, objectType, identifierList, longList)
+	if err != nil || errorsList != nil {
+		return nil, nil, errorsList, err
+	}
+
+	// Call Response operation
+	return consumer.retrieveResponse(ctx)
+}
+
+// Query : Retrieve the groups of objects matching a list of archive queries
+func (client *ArchiveClient) Query(ctx context.Context, boolean *Boolean, objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (*QueryResponse, *ServiceError, error) {
+	stream, errorsList, err := client.QueryStream(ctx, boolean, objectType, archiveQueryList, queryFilterList)
+	if err != nil || errorsList != nil {
+		return nil, errorsList, err
+	}
+	defer stream.Close()
+
+	return stream.readAll(ctx)
+}
+
+// QueryStream : Call the Query operation, the objects are received with
+// the Next method of the stream (see StartQueryStream)
+func (client *ArchiveClient) QueryStream(ctx context.Context, boolean *Boolean, objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (*QueryStream, *ServiceError, error) {
+	octx, err := client.newOperationContext()
+	if err != nil {
+		return nil, nil, err
+	}
+	op := octx.cctx.NewProgressOperation(client.providerURI,
+		COM_AREA_NUMBER,
+		COM_AREA_VERSION,
+		ARCHIVE_SERVICE_SERVICE_NUMBER,
+		OPERATION_IDENTIFIER_QUERY)
+	consumer := &ProgressConsumer{octx, op, client.factory}
+
+	// The consumer is closed with the stream
+	return startQueryStream(ctx, consumer, boolean, objectType, archiveQueryList, queryFilterList)
+}
+
+// Count : Count the objects matching a list of archive queries
+func (client *ArchiveClient) Count(ctx context.Context, objectType *ObjectType, archiveQueryList *ArchiveQueryList, queryFilterList QueryFilterList) (*LongList, *ServiceError, error) {
+	octx, err := client.newOperationContext()
+	if err != nil {
+		return nil, nil, err
+	}
+	op := octx.cctx.NewInvokeOperation(client.providerURI,
+		COM_AREA_NUMBER,
+		COM_AREA_VERSION,
+		ARCHIVE_SERVICE_SERVICE_NUMBER,
+		OPERATION_IDENTIFIER_COUNT)
+	consumer := &InvokeConsumer{octx, op, client.factory}
+	defer consumer.Close()
+
+	// Call Invoke operation
+	errorsList, err := consumer.countInvoke(ctx, objectType, archiveQueryList, queryFilterList)
+	if err != nil || errorsList != nil {
+		return nil, errorsList, err
+	}
+
+	// Call Response operation
+	return consumer.countResponse(ctx)
+}
+
+// Store : Store new objects in the archive
+func (client *ArchiveClient) Store(ctx context.Context, boolean *Boolean, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*LongList, *ServiceError, error) {
+	octx, err := client.newOperationContext()
+	if err != nil {
+		return nil, nil, err
+	}
+	op := octx.cctx.NewRequestOperation(client.providerURI,
+		COM_AREA_NUMBER,
+		COM_AREA_VERSION,
+		ARCHIVE_SERVICE_SERVICE_NUMBER,
+		OPERATION_IDENTIFIER_STORE)
+	consumer := &RequestConsumer{octx, op, client.factory}
+	defer consumer.Close()
+
+	// Call Request operation and retrieve the Response
+	return consumer.storeRequest(ctx, boolean, objectType, identifierList, archiveDetailsList, elementList)
+}
+
+// Update : Update objects of the archive
+func (client *ArchiveClient) Update(ctx context.Context, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*ServiceError, error) {
+	octx, err := client.newOperationContext()
+	if err != nil {
+		return nil, err
+	}
+	op := octx.cctx.NewSubmitOperation(client.providerURI,
+		COM_AREA_NUMBER,
+		COM_AREA_VERSION,
+		ARCHIVE_SERVICE_SERVICE_NUMBER,
+		OPERATION_IDENTIFIER_UPDATE)
+	consumer := &SubmitConsumer{octx, op, client.factory}
+	defer consumer.Close()
+
+	// Call Submit operation
+	return consumer.updateSubmit(ctx, objectType, identifierList, archiveDetailsList, elementList)
+}
+
+// Delete : Delete objects from the archive
+func (client *ArchiveClient) Delete(ctx context.Context, objectType ObjectType, identifierList IdentifierList, longList LongList) (*LongList, *ServiceError, error) {
+	octx, err := client.newOperationContext()
+	if err != nil {
+		return nil, nil, err
+	}
+	op := octx.cctx.NewRequestOperation(client.providerURI,
+		COM_AREA_NUMBER,
+		COM_AREA_VERSION,
+		ARCHIVE_SERVICE_SERVICE_NUMBER,
+		OPERATION_IDENTIFIER_DELETE)
+	consumer := &RequestConsumer{octx, op, client.factory}
+	defer consumer.Close()
+
+	// Call Request operation and retrieve the Response
+	return consumer.deleteRequest(ctx, objectType, identifierList, longList)
+}
+
+// newOperationContext creates the client context of an operation in the
+// context of the client, it is closed with the consumer of the operation
+func (client *ArchiveClient) newOperationContext() (*operationContext, error) {
+	cctx, err := newClientContext(client.ctx, "archiveClient")
+	if err != nil {
+		return nil, err
+	}
+	return &operationContext{ctx: client.ctx, cctx: cctx}, nil
+}
@@ -24,6 +24,8 @@
 package consumer
 
 import (
+	"context"
+
 	. "github.com/ccsdsmo/malgo/com"
 	. "github.com/ccsdsmo/malgo/mal"
 	. "github.com/ccsdsmo/malgo/mal/api"
@@ -36,54 +38,54 @@ import (
 
 // InvokeConsumer : TODO:
 type InvokeConsumer struct {
-	ctx     *Context
-	cctx    *ClientContext
+	*operationContext
 	op      InvokeOperation
 	factory EncodingFactory
 }
 
 // ProgressConsumer : TODO:
 type ProgressConsumer struct {
-	ctx     *Context
-	cctx    *ClientContext
+	*operationContext
 	op      ProgressOperation
 	factory EncodingFactory
 }
 
 // RequestConsumer : TODO:
 type RequestConsumer struct {
-	ctx     *Context
-	cctx    *ClientContext
+	*operationContext
 	op      RequestOperation
 	factory EncodingFactory
 }
 
 // SubmitConsumer : TODO:
 type SubmitConsumer struct {
-	ctx     *Context
-	cctx    *ClientContext
+	*operationContext
 	op      SubmitOperation
 	factory EncodingFactory
 }
 
-// Close : TODO:
+// Close : Close the client context of the operation, and the context
+// of the consumer unless it is shared with other operations
 func (i *InvokeConsumer) Close() {
-	i.ctx.Close()
+	i.close()
 }
 
-// Close : TODO:
+// Close : Close the client context of the operation, and the context
+// of the consumer unless it is shared with other operations
 func (p *ProgressConsumer) Close() {
-	p.ctx.Close()
+	p.close()
 }
 
-// Close : TODO:
+// Close : Close the client context of the operation, and the context
+// of the consumer unless it is shared with other operations
 func (r *RequestConsumer) Close() {
-	r.ctx.Close()
+	r.close()
 }
 
-// Close : TODO:
+// Close : Close the client context of the operation, and the context
+// of the consumer unless it is shared with other operations
 func (s *SubmitConsumer) Close() {
-	s.ctx.Close()
+	s.close()
 }
 
 //======================================================================//
@@ -96,8 +98,9 @@ func createInvokeConsumer(url string, providerURI *URI, typeOfConsumer string, o
 		return nil, err
 	}
 
-	cctx, err := NewClientContext(ctx, typeOfConsumer)
+	cctx, err := newClientContext(ctx, typeOfConsumer)
 	if err != nil {
+		ctx.Close()
 		return nil, err
 	}
 
@@ -109,7 +112,7 @@ func createInvokeConsumer(url string, providerURI *URI, typeOfConsumer string, o
 
 	factory := new(FixedBinaryEncoding)
 
-	consumer := &InvokeConsumer{ctx, cctx, op, factory}
+	consumer := &InvokeConsumer{&operationContext{ctx: ctx, cctx: cctx, ownsContext: true}, op, factory}
 
 	return consumer, nil
 }
@@ -121,8 +124,9 @@ func createProgressConsumer(url string, providerURI *URI, typeOfConsumer string,
 		return nil, err
 	}
 
-	cctx, err := NewClientContext(ctx, typeOfConsumer)
+	cctx, err := newClientContext(ctx, typeOfConsumer)
 	if err != nil {
+		ctx.Close()
 		return nil, err
 	}
 
@@ -134,7 +138,7 @@ func createProgressConsumer(url string, providerURI *URI, typeOfConsumer string,
 
 	factory := new(FixedBinaryEncoding)
 
-	consumer := &ProgressConsumer{ctx, cctx, op, factory}
+	consumer := &ProgressConsumer{&operationContext{ctx: ctx, cctx: cctx, ownsContext: true}, op, factory}
 
 	return consumer, nil
 }
@@ -146,8 +150,9 @@ func createRequestConsumer(url string, providerURI *URI, typeOfConsumer string,
 		return nil, err
 	}
 
-	cctx, err := NewClientContext(ctx, typeOfConsumer)
+	cctx, err := newClientContext(ctx, typeOfConsumer)
 	if err != nil {
+		ctx.Close()
 		return nil, err
 	}
 
@@ -159,7 +164,7 @@ func createRequestConsumer(url string, providerURI *URI, typeOfConsumer string,
 
 	factory := new(FixedBinaryEncoding)
 
-	consumer := &RequestConsumer{ctx, cctx, op, factory}
+	consumer := &RequestConsumer{&operationContext{ctx: ctx, cctx: cctx, ownsContext: true}, op, factory}
 
 	return consumer, nil
 }
@@ -171,8 +176,9 @@ func createSubmitConsumer(url string, providerURI *URI, typeOfConsumer string, o
 		return nil, err
 	}
 
-	cctx, err := NewClientContext(ctx, typeOfConsumer)
+	cctx, err := newClientContext(ctx, typeOfConsumer)
 	if err != nil {
+		ctx.Close()
 		return nil, err
 	}
 
@@ -184,7 +190,7 @@ func createSubmitConsumer(url string, providerURI *URI, typeOfConsumer string, o
 
 	factory := new(FixedBinaryEncoding)
 
-	consumer := &SubmitConsumer{ctx, cctx, op, factory}
+	consumer := &SubmitConsumer{&operationContext{ctx: ctx, cctx: cctx, ownsContext: true}, op, factory}
 
 	return consumer, nil
 }
@@ -193,7 +199,7 @@ func createSubmitConsumer(url string, providerURI *URI, typeOfConsumer string, o
 //								RETRIEVE								//
 //======================================================================//
 // StartRetrieveConsumer : TODO:
-func StartRetrieveConsumer(url string, providerURI *URI, objectType ObjectType, identifierList IdentifierList, longList LongList) (*InvokeConsumer, *ArchiveDetailsList, ElementList, *ServiceError, error) {
+func StartRetrieveConsumer(ctx context.Context, url string, providerURI *URI, objectType ObjectType, identifierList IdentifierList, longList LongList) (*InvokeConsumer, *ArchiveDetailsList, ElementList, *ServiceError, error) {
 	// Create the consumer
 	consumer, err := createInvokeConsumer(url, providerURI, "consumerRetrieve", OPERATION_IDENTIFIER_RETRIEVE)
 	if err != nil {
@@ -201,7 +207,7 @@ func StartRetrieveConsumer(url string, providerURI *URI, objectType ObjectType,
 	}
 
 	// Call Invoke operation
-	errorsList, err := consumer.retrieveInvoke(objectType, identifierList, longList)
+	errorsList, err := consumer.retrieveInvoke(ctx, objectType, identifierList, longList)
 	if err != nil {
 		// Close consummer
 		consumer.Close()
@@ -213,7 +219,7 @@ func StartRetrieveConsumer(url string, providerURI *URI, objectType ObjectType,
 	}
 
 	// Call Response operation
-	archiveDetailsList, elementList, errorsList, err := consumer.retrieveResponse()
+	archiveDetailsList, elementList, errorsList, err := consumer.retrieveResponse(ctx)
 	if err != nil {
 		// Close consummer
 		consumer.Close()
@@ -228,7 +234,7 @@ func StartRetrieveConsumer(url string, providerURI *URI, objectType ObjectType,
 }
 
 // Invoke & Ack : TODO:
-func (consumer *InvokeConsumer) retrieveInvoke(objectType ObjectType, identifierList IdentifierList, longList LongList) (*ServiceError, error) {
+func (consumer *InvokeConsumer) retrieveInvoke(ctx context.Context, objectType ObjectType, identifierList IdentifierList, longList LongList) (*ServiceError, error) {
 	// Create the encoder
 	encoder := consumer.factory.NewEncoder(make([]byte, 0, LENGTH))
 	// Encode ObjectType
@@ -250,10 +256,12 @@ func (consumer *InvokeConsumer) retrieveInvoke(objectType ObjectType, identifier
 	}
 
 	// Call Invoke operation
-	resp, err := consumer.op.Invoke(encoder.Body())
+	resp, err := waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+		return consumer.op.Invoke(encoder.Body())
+	})
 	if err != nil {
 		// Verify if an error occurs during the operation
-		if resp.IsErrorMessage {
+		if resp != nil && resp.IsErrorMessage {
 			// Create the decoder
 			decoder := consumer.factory.NewDecoder(resp.Body)
 			// Decode the error
@@ -271,12 +279,14 @@ func (consumer *InvokeConsumer) retrieveInvoke(objectType ObjectType, identifier
 }
 
 // Response : TODO:
-func (consumer *InvokeConsumer) retrieveResponse() (*ArchiveDetailsList, ElementList, *ServiceError, error) {
+func (consumer *InvokeConsumer) retrieveResponse(ctx context.Context) (*ArchiveDetailsList, ElementList, *ServiceError, error) {
 	// Call Response operation
-	resp, err := consumer.op.GetResponse()
+	resp, err := waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+		return consumer.op.GetResponse()
+	})
 	if err != nil {
 		// Verify if an error occurs during the operation
-		if resp.IsErrorMessage {
+		if resp != nil && resp.IsErrorMessage {
 			// Create the decoder
 			decoder := consumer.factory.NewDecoder(resp.Body)
 			// Decode the error
@@ -311,238 +321,240 @@ func (consumer *InvokeConsumer) retrieveResponse() (*ArchiveDetailsList, Element
 //======================================================================//
 //								QUERY									//
 //======================================================================//
-// StartQueryConsumer : TODO:
-func StartQueryConsumer(url string, providerURI *URI, boolean *Boolean, objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (*ProgressConsumer, []interface{}, *ServiceError, error) {
-	// Create the consumer
-	consumer, err := createProgressConsumer(url, providerURI, "consumerQuery", OPERATION_IDENTIFIER_QUERY)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	// Call Progress function
-	errorsList, err := consumer.queryProgress(boolean, objectType, archiveQueryList, queryFilterList)
-	if err != nil {
-		// Close consummer
-		consumer.Close()
-		return nil, nil, nil, err
-	} else if errorsList != nil {
-		// Close consummer
-		consumer.Close()
-		return nil, nil, errorsList, nil
-	}
-
-	// Create the interface that will receive all the responses
-	responses := []interface{}{}
-	// Call Update operation
-	respObjType, respIDList, respArchDetList, respElemList, errorsList, err := consumer.queryUpdate()
+// StartQueryConsumer : Call the Query operation, the groups of objects are
+// returned in the order of the archive queries which selected them
+func StartQueryConsumer(ctx context.Context, url string, providerURI *URI, boolean *Boolean, objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (*ProgressConsumer, *QueryResponse, *ServiceError, error) {
+	// Start the operation
+	stream, errorsList, err := StartQueryStream(ctx, url, providerURI, boolean, objectType, archiveQueryList, queryFilterList)
 	if err != nil {
-		// Close consummer
-		consumer.Close()
 		return nil, nil, nil, err
 	} else if errorsList != nil {
-		// Close consummer
-		consumer.Close()
 		return nil, nil, errorsList, nil
 	}
 
-	for respArchDetList != nil {
-		// Put the objects in the interface
-		responses = append(responses, respObjType, respIDList, respArchDetList, respElemList)
-
-		// Call Update operation until it returns nil variables
-		respObjType, respIDList, respArchDetList, respElemList, errorsList, err = consumer.queryUpdate()
-		if err != nil {
-			// Close consummer
-			consumer.Close()
-			return nil, nil, nil, err
-		} else if errorsList != nil {
-			// Close consummer
-			consumer.Close()
-			return nil, nil, errorsList, nil
-		}
-	}
-
-	// Call Response operation
-	respObjType, respIDList, respArchDetList, respElemList, errorsList, err = consumer.queryResponse()
+	// Receive all the groups
+	response, errorsList, err := stream.readAll(ctx)
 	if err != nil {
 		// Close consummer
-		consumer.Close()
+		stream.Close()
 		return nil, nil, nil, err
 	} else if errorsList != nil {
 		// Close consummer
-		consumer.Close()
+		stream.Close()
 		return nil, nil, errorsList, nil
 	}
 
-	// Put the objects in the interface
-	responses = append(responses, respObjType, respIDList, respArchDetList, respElemList)
-
-	return consumer, responses, nil, nil
+	return stream.consumer, response, nil, nil
 }
 
-// Progress & Ack : TODO:
-func (consumer *ProgressConsumer) queryProgress(boolean *Boolean, objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (*ServiceError, error) {
+// Progress & Ack : Call the Query operation and ask for indexed updates. The
+// returned boolean is true if the provider sends them, a provider of the
+// specification ignores the request and sends the updates of the specification
+func (consumer *ProgressConsumer) queryProgress(ctx context.Context, boolean *Boolean, objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (bool, *ServiceError, error) {
 	// Create the encoder
 	encoder := consumer.factory.NewEncoder(make([]byte, 0, LENGTH))
 
 	// Encode Boolean
 	err := encoder.EncodeNullableElement(boolean)
 	if err != nil {
-		return nil, err
+		return false, nil, err
 	}
 
 	// Encode ObjectType
 	err = objectType.Encode(encoder)
 	if err != nil {
-		return nil, err
+		return false, nil, err
 	}
 
 	// Encode ArchiveQueryList
 	err = archiveQueryList.Encode(encoder)
 	if err != nil {
-		return nil, err
+		return false, nil, err
 	}
 
 	// Encode QueryFilterList
 	err = encoder.EncodeNullableAbstractElement(queryFilterList)
 	if err != nil {
-		return nil, err
+		return false, nil, err
+	}
+
+	// Encode Boolean, ask for indexed updates
+	err = NewBoolean(true).Encode(encoder)
+	if err != nil {
+		return false, nil, err
 	}
 
 	// Call Progress operation
-	resp, err := consumer.op.Progress(encoder.Body())
+	resp, err := waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+		return consumer.op.Progress(encoder.Body())
+	})
 	if err != nil {
 		// Verify if an error occurs during the operation
-		if resp.IsErrorMessage {
+		if resp != nil && resp.IsErrorMessage {
 			// Create the decoder
 			decoder := consumer.factory.NewDecoder(resp.Body)
 			// Decode the error
 			errorsList, err := DecodeError(decoder)
 			if err != nil {
-				return nil, err
+				return false, nil, err
 			}
 
-			return errorsList, nil
+			return false, errorsList, nil
 		}
-		return nil, err
+		return false, nil, err
 	}
 
-	return nil, nil
+	// The Ack of the specification is empty
+	if len(resp.Body) == 0 {
+		return false, nil, nil
+	}
+
+	// Create the decoder
+	decoder := consumer.factory.NewDecoder(resp.Body)
+
+	// Decode Boolean
+	isIndexed, err := decoder.DecodeElement(NullBoolean)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return bool(*isIndexed.(*Boolean)), nil, nil
 }
 
-// Update : TODO:
-func (consumer *ProgressConsumer) queryUpdate() (*ObjectType, *IdentifierList, *ArchiveDetailsList, ElementList, *ServiceError, error) {
+// Update : Receive a group of objects, or a part of it. For an indexed
+// update, the returned boolean is true if the next update holds the next
+// objects of the same group. The index of the query of the updates of the
+// specification isn't known. The result is nil once all the updates are
+// received
+func (consumer *ProgressConsumer) queryUpdate(ctx context.Context, isIndexed bool) (*QueryResult, bool, *ServiceError, error) {
 	// Call Update operation
-	updt, err := consumer.op.GetUpdate()
+	updt, err := waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+		return consumer.op.GetUpdate()
+	})
 	if err != nil {
 		// Verify if an error occurs during the operation
-		if updt.IsErrorMessage {
+		if updt != nil && updt.IsErrorMessage {
 			// Create the decoder
 			decoder := consumer.factory.NewDecoder(updt.Body)
 			// Decode the error
 			errorsList, err := DecodeError(decoder)
 			if err != nil {
-				return nil, nil, nil, nil, nil, err
+				return nil, false, nil, err
 			}
 
-			return nil, nil, nil, nil, errorsList, nil
+			return nil, false, errorsList, nil
 		}
-		return nil, nil, nil, nil, nil, err
+		return nil, false, nil, err
 	}
 
-	if updt != nil {
-		// Create the decoder to decode the multiple variables
-		decoder := consumer.factory.NewDecoder(updt.Body)
-
-		// Decode ObjectType
-		objectType, err := decoder.DecodeNullableElement(NullObjectType)
-		if err != nil {
-			return nil, nil, nil, nil, nil, err
-		}
+	if updt == nil {
+		return nil, false, nil, nil
+	}
 
-		// Decode IdentifierList
-		identifierList, err := decoder.DecodeNullableElement(NullIdentifierList)
-		if err != nil {
-			return nil, nil, nil, nil, nil, err
-		}
+	// Create the decoder to decode the multiple variables
+	decoder := consumer.factory.NewDecoder(updt.Body)
 
-		// Decode ArchiveDetailsList
-		archiveDetailsList, err := decoder.DecodeNullableElement(NullArchiveDetailsList)
-		if err != nil {
-			return nil, nil, nil, nil, nil, err
-		}
+	result, err := decodeQueryResult(decoder)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	if !isIndexed {
+		result.QueryIndex = -1
+		return result, false, nil, nil
+	}
 
-		// Decode ElementList
-		elementList, err := decoder.DecodeNullableAbstractElement()
-		if err != nil {
-			return nil, nil, nil, nil, nil, err
-		}
+	// Decode Boolean
+	isContinued, err := decoder.DecodeElement(NullBoolean)
+	if err != nil {
+		return nil, false, nil, err
+	}
 
-		return objectType.(*ObjectType), identifierList.(*IdentifierList), archiveDetailsList.(*ArchiveDetailsList), elementList.(ElementList), nil, nil
+	// Decode UInteger
+	queryIndex, err := decoder.DecodeElement(NullUInteger)
+	if err != nil {
+		return nil, false, nil, err
 	}
-	return nil, nil, nil, nil, nil, nil
+	result.QueryIndex = int(*queryIndex.(*UInteger))
+
+	return result, bool(*isContinued.(*Boolean)), nil, nil
 }
 
-// Response : TODO:
-func (consumer *ProgressConsumer) queryResponse() (*ObjectType, *IdentifierList, *ArchiveDetailsList, ElementList, *ServiceError, error) {
+// Response : Receive the last group of objects, or its last part
+func (consumer *ProgressConsumer) queryResponse(ctx context.Context) (*QueryResult, *ServiceError, error) {
 	// Call Update operation
-	resp, err := consumer.op.GetResponse()
+	resp, err := waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+		return consumer.op.GetResponse()
+	})
 	if err != nil {
 		// Verify if an error occurs during the operation
-		if resp.IsErrorMessage {
+		if resp != nil && resp.IsErrorMessage {
 			// Create the decoder
 			decoder := consumer.factory.NewDecoder(resp.Body)
 			// Decode the error
 			errorsList, err := DecodeError(decoder)
 			if err != nil {
-				return nil, nil, nil, nil, nil, err
+				return nil, nil, err
 			}
 
-			return nil, nil, nil, nil, errorsList, nil
+			return nil, errorsList, nil
 		}
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, err
 	}
 
 	// Create the decoder to decode the multiple variables
 	decoder := consumer.factory.NewDecoder(resp.Body)
 
+	result, err := decodeQueryResult(decoder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, nil, nil
+}
+
+// decodeQueryResult decodes a group of objects sent by the provider
+func decodeQueryResult(decoder Decoder) (*QueryResult, error) {
 	// Decode ObjectType
 	objectType, err := decoder.DecodeNullableElement(NullObjectType)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, err
 	}
 
 	// Decode IdentifierList
 	identifierList, err := decoder.DecodeNullableElement(NullIdentifierList)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, err
 	}
 
 	// Decode ArchiveDetailsList
 	archiveDetailsList, err := decoder.DecodeNullableElement(NullArchiveDetailsList)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, err
 	}
 
 	// Decode ElementList
 	elementList, err := decoder.DecodeNullableAbstractElement()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, err
 	}
 
-	// Not a good method but it works...
-	if elementList == nil {
-		return objectType.(*ObjectType), identifierList.(*IdentifierList), archiveDetailsList.(*ArchiveDetailsList), nil, nil, nil
+	result := &QueryResult{
+		ObjectType:         objectType.(*ObjectType),
+		Domain:             identifierList.(*IdentifierList),
+		ArchiveDetailsList: archiveDetailsList.(*ArchiveDetailsList),
 	}
-
-	return objectType.(*ObjectType), identifierList.(*IdentifierList), archiveDetailsList.(*ArchiveDetailsList), elementList.(ElementList), nil, nil
+	// The elements are only sent if they are requested
+	if elementList != nil {
+		result.ElementList = elementList.(ElementList)
+	}
+	return result, nil
 }
 
 //======================================================================//
 //								COUNT									//
 //======================================================================//
 // StartCountConsumer : TODO:
-func StartCountConsumer(url string, providerURI *URI, objectType *ObjectType, archiveQueryList *ArchiveQueryList, queryFilterList QueryFilterList) (*InvokeConsumer, *LongList, *ServiceError, error) {
+func StartCountConsumer(ctx context.Context, url string, providerURI *URI, objectType *ObjectType, archiveQueryList *ArchiveQueryList, queryFilterList QueryFilterList) (*InvokeConsumer, *LongList, *ServiceError, error) {
 	// Create the consumer
 	consumer, err := createInvokeConsumer(url, providerURI, "consumerCount", OPERATION_IDENTIFIER_COUNT)
 	if err != nil {
@@ -550,7 +562,7 @@ func StartCountConsumer(url string, providerURI *URI, objectType *ObjectType, ar
 	}
 
 	// Call Invoke function
-	errorsList, err := consumer.countInvoke(objectType, archiveQueryList, queryFilterList)
+	errorsList, err := consumer.countInvoke(ctx, objectType, archiveQueryList, queryFilterList)
 	if err != nil {
 		// Close consummer
 		consumer.Close()
@@ -562,7 +574,7 @@ func StartCountConsumer(url string, providerURI *URI, objectType *ObjectType, ar
 	}
 
 	// Call Response function
-	longList, errorsList, err := consumer.countResponse()
+	longList, errorsList, err := consumer.countResponse(ctx)
 	if err != nil {
 		// Close consummer
 		consumer.Close()
@@ -577,7 +589,7 @@ func StartCountConsumer(url string, providerURI *URI, objectType *ObjectType, ar
 }
 
 // Invoke & Ack : TODO:
-func (consumer *InvokeConsumer) countInvoke(objectType *ObjectType, archiveQueryList *ArchiveQueryList, queryFilterList QueryFilterList) (*ServiceError, error) {
+func (consumer *InvokeConsumer) countInvoke(ctx context.Context, objectType *ObjectType, archiveQueryList *ArchiveQueryList, queryFilterList QueryFilterList) (*ServiceError, error) {
 	// Create the encoder
 	encoder := consumer.factory.NewEncoder(make([]byte, 0, LENGTH))
 
@@ -601,10 +613,12 @@ func (consumer *InvokeConsumer) countInvoke(objectType *ObjectType, archiveQuery
 
 	// Call Invoke operation
 	// TODO: we should retrieve the msg to verify if the ack is an error or not
-	resp, err := consumer.op.Invoke(encoder.Body())
+	resp, err := waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+		return consumer.op.Invoke(encoder.Body())
+	})
 	if err != nil {
 		// Verify if an error occurs during the operation
-		if resp.IsErrorMessage {
+		if resp != nil && resp.IsErrorMessage {
 			// Create the decoder
 			decoder := consumer.factory.NewDecoder(resp.Body)
 			// Decode the error
@@ -622,12 +636,14 @@ func (consumer *InvokeConsumer) countInvoke(objectType *ObjectType, archiveQuery
 }
 
 // Response : TODO:
-func (consumer *InvokeConsumer) countResponse() (*LongList, *ServiceError, error) {
+func (consumer *InvokeConsumer) countResponse(ctx context.Context) (*LongList, *ServiceError, error) {
 	// Call Response operation
-	resp, err := consumer.op.GetResponse()
+	resp, err := waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+		return consumer.op.GetResponse()
+	})
 	if err != nil {
 		// Verify if an error occurs during the operation
-		if resp.IsErrorMessage {
+		if resp != nil && resp.IsErrorMessage {
 			// Create the decoder
 			decoder := consumer.factory.NewDecoder(resp.Body)
 			// Decode the error
@@ -657,7 +673,7 @@ func (consumer *InvokeConsumer) countResponse() (*LongList, *ServiceError, error
 //								STORE									//
 //======================================================================//
 // StartStoreConsumer : TODO:
-func StartStoreConsumer(url string, providerURI *URI, boolean *Boolean, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*RequestConsumer, *LongList, *ServiceError, error) {
+func StartStoreConsumer(ctx context.Context, url string, providerURI *URI, boolean *Boolean, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*RequestConsumer, *LongList, *ServiceError, error) {
 	// Create the consumer
 	consumer, err := createRequestConsumer(url, providerURI, "consumerStore", OPERATION_IDENTIFIER_STORE)
 	if err != nil {
@@ -665,7 +681,7 @@ func StartStoreConsumer(url string, providerURI *URI, boolean *Boolean, objectTy
 	}
 
 	// Call Request function and retrieve the Response
-	longList, errorsList, err := consumer.storeRequest(boolean, objectType, identifierList, archiveDetailsList, elementList)
+	longList, errorsList, err := consumer.storeRequest(ctx, boolean, objectType, identifierList, archiveDetailsList, elementList)
 	if err != nil {
 		// Close consummer
 		consumer.Close()
@@ -680,7 +696,7 @@ func StartStoreConsumer(url string, providerURI *URI, boolean *Boolean, objectTy
 }
 
 // Request & Response : TODO:
-func (consumer *RequestConsumer) storeRequest(boolean *Boolean, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*LongList, *ServiceError, error) {
+func (consumer *RequestConsumer) storeRequest(ctx context.Context, boolean *Boolean, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*LongList, *ServiceError, error) {
 	// Create the encoder
 	encoder := consumer.factory.NewEncoder(make([]byte, 0, LENGTH))
 
@@ -715,10 +731,12 @@ func (consumer *RequestConsumer) storeRequest(boolean *Boolean, objectType Objec
 	}
 
 	// Call Request operation and retrieve the Response
-	resp, err := consumer.op.Request(encoder.Body())
+	resp, err := waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+		return consumer.op.Request(encoder.Body())
+	})
 	if err != nil {
 		// Verify if an error occurs during the operation
-		if resp.IsErrorMessage {
+		if resp != nil && resp.IsErrorMessage {
 			// Create the decoder
 			decoder := consumer.factory.NewDecoder(resp.Body)
 			// Decode the error
@@ -748,7 +766,7 @@ func (consumer *RequestConsumer) storeRequest(boolean *Boolean, objectType Objec
 //								UPDATE									//
 //======================================================================//
 // StartUpdateConsumer : TODO:
-func StartUpdateConsumer(url string, providerURI *URI, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*SubmitConsumer, *ServiceError, error) {
+func StartUpdateConsumer(ctx context.Context, url string, providerURI *URI, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*SubmitConsumer, *ServiceError, error) {
 	// Create the consumer
 	consumer, err := createSubmitConsumer(url, providerURI, "consumerUpdate", OPERATION_IDENTIFIER_UPDATE)
 	if err != nil {
@@ -756,7 +774,7 @@ func StartUpdateConsumer(url string, providerURI *URI, objectType ObjectType, id
 	}
 
 	// Call Submit function
-	errorsList, err := consumer.updateSubmit(objectType, identifierList, archiveDetailsList, elementList)
+	errorsList, err := consumer.updateSubmit(ctx, objectType, identifierList, archiveDetailsList, elementList)
 	if err != nil {
 		// Close consummer
 		consumer.Close()
@@ -771,7 +789,7 @@ func StartUpdateConsumer(url string, providerURI *URI, objectType ObjectType, id
 }
 
 // Submit & Ack : TODO:
-func (consumer *SubmitConsumer) updateSubmit(objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*ServiceError, error) {
+func (consumer *SubmitConsumer) updateSubmit(ctx context.Context, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*ServiceError, error) {
 	// Create the encoder
 	encoder := consumer.factory.NewEncoder(make([]byte, 0, LENGTH))
 
@@ -800,10 +818,12 @@ func (consumer *SubmitConsumer) updateSubmit(objectType ObjectType, identifierLi
 	}
 
 	// Call Submit operation
-	resp, err := consumer.op.Submit(encoder.Body())
+	resp, err := waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+		return consumer.op.Submit(encoder.Body())
+	})
 	if err != nil {
 		// Verify if an error occurs during the operation
-		if resp.IsErrorMessage {
+		if resp != nil && resp.IsErrorMessage {
 			// Create the decoder
 			decoder := consumer.factory.NewDecoder(resp.Body)
 			// Decode the error
@@ -824,7 +844,7 @@ func (consumer *SubmitConsumer) updateSubmit(objectType ObjectType, identifierLi
 //								DELETE									//
 //======================================================================//
 // StartDeleteConsumer : TODO:
-func StartDeleteConsumer(url string, providerURI *URI, objectType ObjectType, identifierList IdentifierList, longList LongList) (*RequestConsumer, *LongList, *ServiceError, error) {
+func StartDeleteConsumer(ctx context.Context, url string, providerURI *URI, objectType ObjectType, identifierList IdentifierList, longList LongList) (*RequestConsumer, *LongList, *ServiceError, error) {
 	// Create the consumer
 	consumer, err := createRequestConsumer(url, providerURI, "consumerDelete", OPERATION_IDENTIFIER_DELETE)
 	if err != nil {
@@ -832,7 +852,7 @@ func StartDeleteConsumer(url string, providerURI *URI, objectType ObjectType, id
 	}
 
 	// Call Request function and retrieve the Response
-	respLongList, errorsList, err := consumer.deleteRequest(objectType, identifierList, longList)
+	respLongList, errorsList, err := consumer.deleteRequest(ctx, objectType, identifierList, longList)
 	if err != nil {
 		// Close consummer
 		consumer.Close()
@@ -847,7 +867,7 @@ func StartDeleteConsumer(url string, providerURI *URI, objectType ObjectType, id
 }
 
 // Request & Reponse : TODO:
-func (consumer *RequestConsumer) deleteRequest(objectType ObjectType, identifierList IdentifierList, longList LongList) (*LongList, *ServiceError, error) {
+func (consumer *RequestConsumer) deleteRequest(ctx context.Context, objectType ObjectType, identifierList IdentifierList, longList LongList) (*LongList, *ServiceError, error) {
 	// Create the encoder
 	encoder := consumer.factory.NewEncoder(make([]byte, 0, LENGTH))
 
@@ -870,10 +890,12 @@ func (consumer *RequestConsumer) deleteRequest(objectType ObjectType, identifier
 	}
 
 	// Call Request operation and retrieve the Response
-	resp, err := consumer.op.Request(encoder.Body())
+	resp, err := waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+		return consumer.op.Request(encoder.Body())
+	})
 	if err != nil {
 		// Verify if an error occurs during the operation
-		if resp.IsErrorMessage {
+		if resp != nil && resp.IsErrorMessage {
 			// Create the decoder
 			decoder := consumer.factory.NewDecoder(resp.Body)
 			// Decode the error
@@ -897,3 +919,226 @@ func (consumer *RequestConsumer) deleteRequest(objectType ObjectType, identifier
 
 	return respLongList.(*LongList), nil, nil
 }
+
+//======================================================================//
+//							STANDING QUERY								//
+//======================================================================//
+// StandingQueryConsumer : A standing query registered with the provider,
+// it receives the objects matching the query as they are stored or updated
+type StandingQueryConsumer struct {
+	*ProgressConsumer
+	providerURI *URI
+	id          Long
+}
+
+// StartStandingQueryConsumer : Register a standing query with the provider. The
+// objects are then received with GetObjects until the query is cancelled
+func StartStandingQueryConsumer(ctx context.Context, url string, providerURI *URI, boolean *Boolean, objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) (*StandingQueryConsumer, *ServiceError, error) {
+	// Create the consumer
+	progressConsumer, err := createProgressConsumer(url, providerURI, "consumerStandingQuery", OPERATION_IDENTIFIER_STANDING_QUERY)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	consumer := &StandingQueryConsumer{ProgressConsumer: progressConsumer, providerURI: providerURI}
+
+	// Call Progress function
+	errorsList, err := consumer.standingQueryProgress(ctx, boolean, objectType, archiveQuery, queryFilter)
+	if err != nil {
+		// Close consumer
+		progressConsumer.Close()
+		return nil, nil, err
+	} else if errorsList != nil {
+		// Close consumer
+		progressConsumer.Close()
+		return nil, errorsList, nil
+	}
+
+	return consumer, nil, nil
+}
+
+// Progress & Ack : Register the standing query and keep its identifier
+func (consumer *StandingQueryConsumer) standingQueryProgress(ctx context.Context, boolean *Boolean, objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) (*ServiceError, error) {
+	// Create the encoder
+	encoder := consumer.factory.NewEncoder(make([]byte, 0, LENGTH))
+
+	// Encode Boolean
+	err := encoder.EncodeNullableElement(boolean)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encode ObjectType
+	err = objectType.Encode(encoder)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encode ArchiveQuery
+	err = archiveQuery.Encode(encoder)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encode QueryFilter
+	err = encoder.EncodeNullableAbstractElement(queryFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call Progress operation
+	resp, err := waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+		return consumer.op.Progress(encoder.Body())
+	})
+	if err != nil {
+		// Verify if an error occurs during the operation
+		if resp != nil && resp.IsErrorMessage {
+			// Create the decoder
+			decoder := consumer.factory.NewDecoder(resp.Body)
+			// Decode the error
+			errorsList, err := DecodeError(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			return errorsList, nil
+		}
+		return nil, err
+	}
+
+	// Create the decoder
+	decoder := consumer.factory.NewDecoder(resp.Body)
+
+	// Decode Long
+	id, err := decoder.DecodeElement(NullLong)
+	if err != nil {
+		return nil, err
+	}
+	consumer.id = *id.(*Long)
+
+	return nil, nil
+}
+
+// GetObjects : Wait for the next object matching the standing query. Each
+// update holds one object, a nil ArchiveDetailsList is returned once the
+// standing query has ended. Once the context is done, the objects aren't
+// received anymore and the consumer must be closed
+func (consumer *StandingQueryConsumer) GetObjects(ctx context.Context) (*ObjectType, *IdentifierList, *ArchiveDetailsList, ElementList, *ServiceError, error) {
+	// Call Update operation
+	updt, err := waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+		return consumer.op.GetUpdate()
+	})
+	if err != nil {
+		// Verify if an error occurs during the operation
+		if updt != nil && updt.IsErrorMessage {
+			// Create the decoder
+			decoder := consumer.factory.NewDecoder(updt.Body)
+			// Decode the error
+			errorsList, err := DecodeError(decoder)
+			if err != nil {
+				return nil, nil, nil, nil, nil, err
+			}
+
+			return nil, nil, nil, nil, errorsList, nil
+		}
+		return nil, nil, nil, nil, nil, err
+	}
+
+	if updt == nil {
+		// The standing query has ended, wait for the Response
+		_, err = waitForMessage(ctx, consumer.abort, func() (*Message, error) {
+			return consumer.op.GetResponse()
+		})
+		return nil, nil, nil, nil, nil, err
+	}
+
+	// Create the decoder to decode the multiple variables
+	decoder := consumer.factory.NewDecoder(updt.Body)
+
+	// Decode ObjectType
+	objectType, err := decoder.DecodeNullableElement(NullObjectType)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	// Decode IdentifierList
+	identifierList, err := decoder.DecodeNullableElement(NullIdentifierList)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	// Decode ArchiveDetailsList
+	archiveDetailsList, err := decoder.DecodeNullableElement(NullArchiveDetailsList)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	// Decode ElementList
+	elementList, err := decoder.DecodeNullableAbstractElement()
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	// The elements are only sent if they are requested
+	if elementList == nil {
+		return objectType.(*ObjectType), identifierList.(*IdentifierList), archiveDetailsList.(*ArchiveDetailsList), nil, nil, nil
+	}
+
+	return objectType.(*ObjectType), identifierList.(*IdentifierList), archiveDetailsList.(*ArchiveDetailsList), elementList.(ElementList), nil, nil
+}
+
+// Cancel : Cancel the standing query, the provider ends it and stops
+// sending the objects
+func (consumer *StandingQueryConsumer) Cancel(ctx context.Context) (*ServiceError, error) {
+	// The Submit operation has its own client context in the context of
+	// the consumer, it can be called once GetObjects has been aborted
+	cctx, err := newClientContext(consumer.ctx, "consumerCancelStandingQuery")
+	if err != nil {
+		return nil, err
+	}
+	op := cctx.NewSubmitOperation(consumer.providerURI,
+		COM_AREA_NUMBER,
+		COM_AREA_VERSION,
+		ARCHIVE_SERVICE_SERVICE_NUMBER,
+		OPERATION_IDENTIFIER_CANCEL_STANDING_QUERY)
+	submitConsumer := &SubmitConsumer{&operationContext{ctx: consumer.ctx, cctx: cctx}, op, consumer.factory}
+	defer submitConsumer.Close()
+
+	// Create the encoder
+	encoder := consumer.factory.NewEncoder(make([]byte, 0, LENGTH))
+
+	// Encode Long
+	err = consumer.id.Encode(encoder)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call Submit operation
+	resp, err := waitForMessage(ctx, submitConsumer.abort, func() (*Message, error) {
+		return op.Submit(encoder.Body())
+	})
+	if err != nil {
+		// Verify if an error occurs during the operation
+		if resp != nil && resp.IsErrorMessage {
+			// Create the decoder
+			decoder := consumer.factory.NewDecoder(resp.Body)
+			// Decode the error
+			errorsList, err := DecodeError(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			return errorsList, nil
+		}
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// Close : Cancel the standing query if it is still running and
+// close the context of the consumer
+func (consumer *StandingQueryConsumer) Close() {
+	consumer.Cancel(context.Background())
+	consumer.ProgressConsumer.Close()
+}
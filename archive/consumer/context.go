@@ -0,0 +1,134 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package consumer
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	. "github.com/ccsdsmo/malgo/mal/api"
+
+	. "github.com/etiennelndr/archiveservice/archive/constants"
+)
+
+// Errors returned by the operations of a consumer whose context is done,
+// they can be compared with ==
+var (
+	// ErrCancelled : The context of the operation has been cancelled
+	ErrCancelled = errors.New(string(ARCHIVE_SERVICE_CONSUMER_CANCELLED_ERROR))
+	// ErrTimeout : The deadline of the context of the operation has been exceeded
+	ErrTimeout = errors.New(string(ARCHIVE_SERVICE_CONSUMER_TIMEOUT_ERROR))
+)
+
+// operationContext holds the contexts used by the operation of a consumer.
+// The client context is only used by this operation, closing it aborts the
+// operation. The context may be shared with other operations (ArchiveClient),
+// it is only closed with the consumer which owns it
+type operationContext struct {
+	mutex       sync.Mutex
+	ctx         *Context
+	cctx        *ClientContext
+	ownsContext bool
+	isAborted   bool
+	isClosed    bool
+}
+
+// clientContexts counts the client contexts created by newClientContext
+var clientContexts uint64
+
+// newClientContext creates a client context for a single operation, its
+// name is unique so that several operations can share the same context
+func newClientContext(ctx *Context, typeOfConsumer string) (*ClientContext, error) {
+	var n = atomic.AddUint64(&clientContexts, 1)
+	return NewClientContext(ctx, typeOfConsumer+strconv.FormatUint(n, 10))
+}
+
+// abort closes the client context of the operation, the call of the
+// operation which waits for a message returns
+func (octx *operationContext) abort() {
+	octx.mutex.Lock()
+	defer octx.mutex.Unlock()
+
+	if !octx.isAborted {
+		octx.isAborted = true
+		octx.cctx.Close()
+	}
+}
+
+// close aborts the operation and closes the context if the consumer owns it,
+// it can be called several times
+func (octx *operationContext) close() {
+	octx.abort()
+
+	octx.mutex.Lock()
+	defer octx.mutex.Unlock()
+
+	if octx.ownsContext && !octx.isClosed {
+		octx.isClosed = true
+		octx.ctx.Close()
+	}
+}
+
+// waitForMessage : Calls f, which blocks until a message is received, and
+// returns as soon as the message is received or the context is done. If
+// the context is done first, the operation is aborted and waitForMessage
+// returns at once: it doesn't wait for f, whose result (if any) is dropped
+// in the buffered channel of the results
+func waitForMessage(ctx context.Context, abort func(), f func() (*Message, error)) (*Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	type result struct {
+		msg *Message
+		err error
+	}
+	results := make(chan result, 1)
+	go func() {
+		msg, err := f()
+		results <- result{msg, err}
+	}()
+
+	select {
+	case r := <-results:
+		return r.msg, r.err
+	case <-ctx.Done():
+		// Abort the operation, f may still be blocked
+		abort()
+		return nil, contextError(ctx.Err())
+	}
+}
+
+// contextError : Returns the error of a consumer operation whose context
+// is done, ErrTimeout if the deadline of the context has been exceeded and
+// ErrCancelled if it has been cancelled
+func contextError(err error) error {
+	if err == context.DeadlineExceeded {
+		return ErrTimeout
+	}
+	return ErrCancelled
+}
@@ -0,0 +1,72 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/ccsdsmo/malgo/mal/api"
+)
+
+// TestWaitForMessageKO_Blocked checks that waitForMessage returns when its
+// context is done, even if the aborted call is still blocked
+func TestWaitForMessageKO_Blocked(t *testing.T) {
+	var unblock = make(chan struct{})
+	defer close(unblock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var aborted = false
+	var returned = make(chan error, 1)
+	go func() {
+		_, err := waitForMessage(ctx, func() { aborted = true }, func() (*Message, error) {
+			<-unblock
+			return nil, nil
+		})
+		returned <- err
+	}()
+
+	select {
+	case err := <-returned:
+		if err != ErrTimeout || !aborted {
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.FailNow()
+	}
+
+	// The call is cancelled before it starts
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	_, err := waitForMessage(cancelled, func() {}, func() (*Message, error) {
+		<-unblock
+		return nil, nil
+	})
+	if err != ErrCancelled {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,163 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package consumer
+
+import (
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+
+	. "github.com/etiennelndr/archiveservice/data"
+)
+
+// QueryResult : A group of objects returned by the Query operation. They
+// have the same object type, and the same domain when it is known
+type QueryResult struct {
+	// QueryIndex is the index of the archive query which selected the
+	// objects, -1 if the provider doesn't send it
+	QueryIndex int
+	// ObjectType is the concrete object type of the objects
+	ObjectType *ObjectType
+	// Domain is the domain of the objects. It is the domain of the archive
+	// query when the provider doesn't return it, and nil if it isn't known
+	Domain             *IdentifierList
+	ArchiveDetailsList *ArchiveDetailsList
+	// ElementList is nil when the elements aren't requested
+	ElementList ElementList
+}
+
+// QueryResponse : The groups of objects returned by the Query operation, in
+// the order of the archive queries. The queries which don't select any
+// object don't have any group
+type QueryResponse struct {
+	Results []*QueryResult
+}
+
+// Size : Return the number of objects of the group
+func (result *QueryResult) Size() int {
+	if result.ArchiveDetailsList == nil {
+		return 0
+	}
+	return result.ArchiveDetailsList.Size()
+}
+
+// Object : Return the ObjectId, the ArchiveDetails and the element (nil if
+// the elements aren't requested) of an object of the group
+func (result *QueryResult) Object(index int) (*ObjectId, *ArchiveDetails, Element) {
+	var archiveDetails = (*result.ArchiveDetailsList)[index]
+
+	var objectKey = ObjectKey{InstId: archiveDetails.InstId}
+	if result.Domain != nil {
+		objectKey.Domain = *result.Domain
+	}
+	var objectID = &ObjectId{Type: result.ObjectType, Key: &objectKey}
+
+	var element Element
+	if result.ElementList != nil {
+		element = result.ElementList.GetElementAt(index)
+	}
+	return objectID, archiveDetails, element
+}
+
+// ForEach : Call a function for each object of the group, until it returns
+// false. It returns false if the iteration has been stopped
+func (result *QueryResult) ForEach(f func(objectID *ObjectId, archiveDetails *ArchiveDetails, element Element) bool) bool {
+	for i := 0; i < result.Size(); i++ {
+		if !f(result.Object(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ForQuery : Return the groups of objects selected by an archive query
+func (response *QueryResponse) ForQuery(queryIndex int) []*QueryResult {
+	var results []*QueryResult
+	for _, result := range response.Results {
+		if result.QueryIndex == queryIndex {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// Size : Return the number of objects of all the groups
+func (response *QueryResponse) Size() int {
+	var size = 0
+	for _, result := range response.Results {
+		size += result.Size()
+	}
+	return size
+}
+
+// ForEach : Call a function for each object of all the groups, until it
+// returns false. It returns false if the iteration has been stopped
+func (response *QueryResponse) ForEach(f func(objectID *ObjectId, archiveDetails *ArchiveDetails, element Element) bool) bool {
+	for _, result := range response.Results {
+		if !result.ForEach(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// add adds a group received from the provider, the empty groups are dropped
+func (response *QueryResponse) add(result *QueryResult, archiveQueryList ArchiveQueryList) {
+	if completeQueryResult(result, archiveQueryList) {
+		response.Results = append(response.Results, result)
+	}
+}
+
+// completeQueryResult sets the domain of a group received from the provider,
+// if it is known. It returns false if the group is empty
+func completeQueryResult(result *QueryResult, archiveQueryList ArchiveQueryList) bool {
+	if result.Size() == 0 {
+		return false
+	}
+	// A domain without wildcard isn't returned by the provider if the
+	// elements aren't requested, the objects are in the queried domain
+	if result.Domain == nil && result.QueryIndex >= 0 && result.QueryIndex < archiveQueryList.Size() {
+		result.Domain = archiveQueryList[result.QueryIndex].Domain
+	}
+	return true
+}
+
+// appendQueryResult appends the objects of a part of a group to the
+// previous parts of this group, if there are any
+func appendQueryResult(group *QueryResult, result *QueryResult) *QueryResult {
+	if group == nil {
+		return result
+	}
+	if result.ArchiveDetailsList != nil {
+		if group.ArchiveDetailsList == nil {
+			group.ArchiveDetailsList = NewArchiveDetailsList(0)
+		}
+		*group.ArchiveDetailsList = append(*group.ArchiveDetailsList, *result.ArchiveDetailsList...)
+	}
+	if group.ElementList != nil && result.ElementList != nil {
+		for i := 0; i < result.ElementList.Size(); i++ {
+			group.ElementList.AppendElement(result.ElementList.GetElementAt(i))
+		}
+	}
+	return group
+}
@@ -0,0 +1,166 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package consumer
+
+import (
+	"context"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+
+	. "github.com/etiennelndr/archiveservice/archive/constants"
+	. "github.com/etiennelndr/archiveservice/data"
+	. "github.com/etiennelndr/archiveservice/errors"
+)
+
+// QueryStream : A Query operation whose objects are received as soon as
+// the provider sends them, instead of once all of them are received
+type QueryStream struct {
+	consumer         *ProgressConsumer
+	archiveQueryList ArchiveQueryList
+	// isIndexed is true if the provider sends indexed updates, with the
+	// index of the query and the parts of the big groups
+	isIndexed bool
+	isEnded   bool
+}
+
+// StartQueryStream : Call the Query operation, the objects are then received
+// with Next. The stream must be closed, even before its end
+func StartQueryStream(ctx context.Context, url string, providerURI *URI, boolean *Boolean, objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (*QueryStream, *ServiceError, error) {
+	// Create the consumer
+	consumer, err := createProgressConsumer(url, providerURI, "consumerQuery", OPERATION_IDENTIFIER_QUERY)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return startQueryStream(ctx, consumer, boolean, objectType, archiveQueryList, queryFilterList)
+}
+
+// startQueryStream calls the Query operation with a consumer
+func startQueryStream(ctx context.Context, consumer *ProgressConsumer, boolean *Boolean, objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (*QueryStream, *ServiceError, error) {
+	stream := &QueryStream{consumer: consumer, archiveQueryList: archiveQueryList}
+
+	// Call Progress function
+	isIndexed, errorsList, err := consumer.queryProgress(ctx, boolean, objectType, archiveQueryList, queryFilterList)
+	if err != nil {
+		// Close consummer
+		stream.Close()
+		return nil, nil, err
+	} else if errorsList != nil {
+		// Close consummer
+		stream.Close()
+		return nil, errorsList, nil
+	}
+	stream.isIndexed = isIndexed
+
+	return stream, nil, nil
+}
+
+// Next : Wait for the next objects sent by the provider, nil is returned once
+// all of them are received. A group bigger than the maximum number of objects
+// per update is received in several parts, with the same query index, object
+// type and domain. The archive queries which don't select any object don't
+// return anything. The query index is -1 if the provider doesn't send it
+// (a provider of the specification). Once the context is cancelled or expired, the Query
+// operation is aborted: the stream stops receiving the updates and must be
+// closed
+func (stream *QueryStream) Next(ctx context.Context) (*QueryResult, *ServiceError, error) {
+	for {
+		result, _, errorsList, err := stream.next(ctx)
+		if result == nil || errorsList != nil || err != nil {
+			return nil, errorsList, err
+		}
+		if completeQueryResult(result, stream.archiveQueryList) {
+			return result, nil, nil
+		}
+	}
+}
+
+// Close : Close the consumer, the context is only closed if it isn't the
+// one of an ArchiveClient. The objects which aren't received yet are dropped
+func (stream *QueryStream) Close() {
+	stream.isEnded = true
+	stream.consumer.Close()
+}
+
+// readAll receives all the groups of objects, the big groups split across
+// several indexed updates are put back together. Without indexed updates,
+// the provider doesn't split the groups: each update is a group
+func (stream *QueryStream) readAll(ctx context.Context) (*QueryResponse, *ServiceError, error) {
+	// Create the response that will receive all the groups
+	response := &QueryResponse{}
+	// The first parts of a group split across several updates
+	var group *QueryResult
+	for {
+		// Call Update operation until all the updates are received, then
+		// Response operation
+		result, isContinued, errorsList, err := stream.next(ctx)
+		if err != nil || errorsList != nil {
+			return nil, errorsList, err
+		} else if result == nil {
+			if group != nil {
+				response.add(group, stream.archiveQueryList)
+			}
+			return response, nil, nil
+		}
+
+		// Put the group back together
+		group = appendQueryResult(group, result)
+		if !isContinued {
+			response.add(group, stream.archiveQueryList)
+			group = nil
+		}
+	}
+}
+
+// next receives the next update, then the response. The returned boolean is
+// true if the next result holds the next objects of the same group
+func (stream *QueryStream) next(ctx context.Context) (*QueryResult, bool, *ServiceError, error) {
+	if stream.isEnded {
+		return nil, false, nil, nil
+	}
+
+	// Call Update operation
+	result, isContinued, errorsList, err := stream.consumer.queryUpdate(ctx, stream.isIndexed)
+	if err != nil || errorsList != nil {
+		stream.isEnded = true
+		return nil, false, errorsList, err
+	} else if result != nil {
+		return result, isContinued, nil, nil
+	}
+
+	// All the updates are received, call Response operation
+	stream.isEnded = true
+	result, errorsList, err = stream.consumer.queryResponse(ctx)
+	if err != nil || errorsList != nil {
+		return nil, false, errorsList, err
+	}
+	// The response holds the last group of the last query
+	result.QueryIndex = -1
+	if stream.isIndexed {
+		result.QueryIndex = stream.archiveQueryList.Size() - 1
+	}
+
+	return result, false, nil, nil
+}
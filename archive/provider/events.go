@@ -0,0 +1,160 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package provider
+
+import (
+	"sync"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+
+	. "github.com/etiennelndr/archiveservice/archive/constants"
+	arch "github.com/etiennelndr/archiveservice/archive/storage"
+	"github.com/etiennelndr/archiveservice/archive/utils"
+	. "github.com/etiennelndr/archiveservice/data"
+	evt "github.com/etiennelndr/archiveservice/event/provider"
+)
+
+// The bodies of the ObjectStored, ObjectUpdated and ObjectDeleted events
+// are the ArchiveDetails of the objects, an archived event is read back as
+// an ArchiveDetails whatever its number
+func init() {
+	for _, eventNumber := range []UShort{ARCHIVE_SERVICE_EVENT_OBJECT_STORED, ARCHIVE_SERVICE_EVENT_OBJECT_UPDATED, ARCHIVE_SERVICE_EVENT_OBJECT_DELETED} {
+		utils.RegisterBodyType(ObjectType{
+			Area:    COM_AREA_NUMBER,
+			Service: ARCHIVE_SERVICE_SERVICE_NUMBER,
+			Version: COM_AREA_VERSION,
+			Number:  eventNumber,
+		}, COM_ARCHIVE_DETAILS_SHORT_FORM)
+	}
+}
+
+// eventPublisher publishes the ObjectStored, ObjectUpdated and ObjectDeleted
+// events of the archive to the Event Service. The publisher is nil until the
+// provider starts to publish the events
+type eventPublisher struct {
+	mutex     sync.Mutex
+	publisher *evt.Publisher
+}
+
+// get returns the publisher of the events, nil if they aren't published
+func (events *eventPublisher) get() *evt.Publisher {
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+	return events.publisher
+}
+
+// StartEventPublisher : Allow the provider to publish an event to the Event
+// Service provider for each object stored, updated or deleted in the archive
+func (provider *Provider) StartEventPublisher(eventProviderURI *URI) error {
+	publisher, err := evt.NewPublisher(provider.cctx, eventProviderURI)
+	if err != nil {
+		return err
+	}
+
+	provider.events.mutex.Lock()
+	defer provider.events.mutex.Unlock()
+
+	// Replace the previous publisher
+	if provider.events.publisher != nil {
+		provider.events.publisher.Close()
+	}
+	provider.events.publisher = publisher
+
+	return nil
+}
+
+// StopEventPublisher : Stop the publication of the events of the archive
+func (provider *Provider) StopEventPublisher() {
+	provider.events.mutex.Lock()
+	defer provider.events.mutex.Unlock()
+
+	if provider.events.publisher == nil {
+		return
+	}
+
+	provider.events.publisher.Close()
+	provider.events.publisher = nil
+}
+
+// publishEvents publishes one event for each object of the list. The source
+// of each event is the ObjectId of the object and its body is its ArchiveDetails
+func (provider *Provider) publishEvents(eventNumber UShort, updateType UpdateType, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList) error {
+	publisher := provider.events.get()
+	if publisher == nil || len(archiveDetailsList) == 0 {
+		return nil
+	}
+
+	var eventType = ObjectType{
+		Area:    COM_AREA_NUMBER,
+		Service: ARCHIVE_SERVICE_SERVICE_NUMBER,
+		Version: COM_AREA_VERSION,
+		Number:  eventNumber,
+	}
+	var objectDetailsList = NewObjectDetailsList(0)
+	for _, archiveDetails := range archiveDetailsList {
+		objectDetailsList.AppendElement(&ObjectDetails{
+			Source: &ObjectId{
+				Type: &objectType,
+				Key: &ObjectKey{
+					Domain: identifierList,
+					InstId: archiveDetails.InstId,
+				},
+			},
+		})
+	}
+
+	return publisher.Publish(eventType, updateType, *objectDetailsList, &archiveDetailsList)
+}
+
+// deletedObject is an object returned by the backend with its deletion
+type deletedObject struct {
+	archiveDetails *ArchiveDetails
+	element        Element
+}
+
+// deletedObjects returns the ArchiveDetails and the elements of the deleted
+// objects, read by the backend in the transaction of the delete. They are
+// the bodies of the ObjectDeleted events and the hooks receive them with
+// their elements. An object which couldn't be read only has its identifier
+// (and a nil element), the error which stopped the cursor is returned
+func deletedObjects(cursor arch.ArchiveCursor, longList LongList) (ArchiveDetailsList, []Element, error) {
+	var objectMap = make(map[Long]deletedObject)
+	for cursor.Next() {
+		archiveDetails, element := cursor.Object()
+		objectMap[archiveDetails.InstId] = deletedObject{archiveDetails, element}
+	}
+
+	var archiveDetailsList = *NewArchiveDetailsList(0)
+	var elements []Element
+	for _, objectInstanceIdentifier := range longList {
+		object, ok := objectMap[*objectInstanceIdentifier]
+		if !ok {
+			object.archiveDetails = &ArchiveDetails{InstId: *objectInstanceIdentifier}
+		}
+		archiveDetailsList.AppendElement(object.archiveDetails)
+		elements = append(elements, object.element)
+	}
+	return archiveDetailsList, elements, cursor.Err()
+}
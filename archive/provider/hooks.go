@@ -0,0 +1,267 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package provider
+
+import (
+	"sync"
+	"sync/atomic"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+
+	. "github.com/etiennelndr/archiveservice/archive/constants"
+	. "github.com/etiennelndr/archiveservice/data"
+)
+
+// ChangeType is the operation which changed an object of the archive
+type ChangeType int
+
+// Constants for the types of change
+const (
+	CHANGE_TYPE_STORE ChangeType = iota + 1
+	CHANGE_TYPE_UPDATE
+	CHANGE_TYPE_DELETE
+)
+
+// Change describes an object stored, updated or deleted in the archive. The
+// element of a deleted object is nil if it couldn't be read with its deletion
+type Change struct {
+	Type           ChangeType
+	ObjectType     ObjectType
+	Domain         IdentifierList
+	ArchiveDetails *ArchiveDetails
+	Element        Element
+}
+
+// ChangeHook is a function called for each change of the archive
+type ChangeHook func(change Change)
+
+// changeHook delivers the changes to a hook in their order, in its own
+// goroutine: the operations only queue the changes and never wait for it.
+// The queue is bounded, the changes which don't fit in it are dropped and
+// onOverflow (if any) is called once, in its own goroutine
+type changeHook struct {
+	hook         func(change Change, done <-chan struct{})
+	onOverflow   func()
+	done         chan struct{}
+	mutex        sync.Mutex
+	cond         *sync.Cond
+	queue        []Change
+	stopped      bool
+	isOverflowed bool
+}
+
+// changeHooks holds the hooks of a provider
+type changeHooks struct {
+	mutex  sync.Mutex
+	nextID int
+	hooks  map[int]*changeHook
+	// maxQueued is the maximum number of changes queued for each hook
+	// (0 means no limit) and dropped counts the changes dropped by all
+	// the hooks, both are used atomically
+	maxQueued int64
+	dropped   uint64
+}
+
+// Create the hooks of a provider
+func newChangeHooks() *changeHooks {
+	return &changeHooks{hooks: make(map[int]*changeHook), maxQueued: DEFAULT_MAX_QUEUED_CHANGES}
+}
+
+// AddChangeHook : Call a function for each object stored, updated or deleted
+// in the archive, once the operation has replied to its consumer. The calls
+// are made in order in a goroutine dedicated to the hook, a slow hook delays
+// its next changes but never the operations: once its queue is full (see
+// SetMaxQueuedChanges) the next changes are dropped and counted (see
+// DroppedChanges). The returned function removes the hook, the changes not
+// delivered yet are dropped
+func (provider *Provider) AddChangeHook(hook ChangeHook) func() {
+	return provider.hooks.add(func(change Change, done <-chan struct{}) {
+		hook(change)
+	}, nil, nil)
+}
+
+// ChangeChannel : Receive on a channel each object stored, updated or deleted
+// in the archive (see AddChangeHook), size is the capacity of the channel. The
+// returned function removes the subscription and closes the channel
+func (provider *Provider) ChangeChannel(size int) (<-chan Change, func()) {
+	var changes = make(chan Change, size)
+	var remove = provider.hooks.add(func(change Change, done <-chan struct{}) {
+		// Don't wait for a full channel once the subscription is removed
+		select {
+		case changes <- change:
+		case <-done:
+		}
+	}, func() {
+		close(changes)
+	}, nil)
+	return changes, remove
+}
+
+// SetMaxQueuedChanges : Set the maximum number of changes queued for each
+// hook (0 means no limit). A change which doesn't fit in the queue of a hook
+// is dropped for this hook, the operations never wait for a slow hook
+func (provider *Provider) SetMaxQueuedChanges(maxChanges int) {
+	atomic.StoreInt64(&provider.hooks.maxQueued, int64(maxChanges))
+}
+
+// DroppedChanges : Return the number of changes dropped by the hooks (and the
+// standing queries) since the provider has been created, because their
+// queue was full. A standing query whose queue is full is ended with an
+// update error, so its consumer knows that objects are missing
+func (provider *Provider) DroppedChanges() uint64 {
+	return atomic.LoadUint64(&provider.hooks.dropped)
+}
+
+// add registers a hook and starts its goroutine, onStop is called when
+// the goroutine ends and onOverflow when a change is dropped for the first
+// time. The done channel of the hook is closed when it is removed
+func (hooks *changeHooks) add(hook func(change Change, done <-chan struct{}), onStop func(), onOverflow func()) func() {
+	var h = &changeHook{hook: hook, onOverflow: onOverflow, done: make(chan struct{})}
+	h.cond = sync.NewCond(&h.mutex)
+
+	hooks.mutex.Lock()
+	var id = hooks.nextID
+	hooks.nextID++
+	hooks.hooks[id] = h
+	hooks.mutex.Unlock()
+
+	go h.run(onStop)
+
+	return func() {
+		hooks.mutex.Lock()
+		delete(hooks.hooks, id)
+		hooks.mutex.Unlock()
+		h.stop()
+	}
+}
+
+// removeAll removes all the hooks, when the provider is closed
+func (hooks *changeHooks) removeAll() {
+	hooks.mutex.Lock()
+	defer hooks.mutex.Unlock()
+
+	for id, h := range hooks.hooks {
+		delete(hooks.hooks, id)
+		h.stop()
+	}
+}
+
+// isEmpty returns true if there isn't any hook
+func (hooks *changeHooks) isEmpty() bool {
+	hooks.mutex.Lock()
+	defer hooks.mutex.Unlock()
+	return len(hooks.hooks) == 0
+}
+
+// notify queues a change for each object of the list in all the hooks, the
+// changes which don't fit in the queue of a hook are dropped for this hook
+func (hooks *changeHooks) notify(changeType ChangeType, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elements []Element) {
+	hooks.mutex.Lock()
+	defer hooks.mutex.Unlock()
+
+	if len(hooks.hooks) == 0 {
+		return
+	}
+
+	var changes = make([]Change, len(archiveDetailsList))
+	for i, archiveDetails := range archiveDetailsList {
+		changes[i] = Change{
+			Type:           changeType,
+			ObjectType:     objectType,
+			Domain:         identifierList,
+			ArchiveDetails: archiveDetails,
+		}
+		if i < len(elements) {
+			changes[i].Element = elements[i]
+		}
+	}
+
+	var maxQueued = int(atomic.LoadInt64(&hooks.maxQueued))
+	for _, h := range hooks.hooks {
+		h.mutex.Lock()
+		var queued = changes
+		if maxQueued > 0 && len(h.queue)+len(queued) > maxQueued {
+			// Keep the oldest changes, the hook receives them in order
+			var free = maxQueued - len(h.queue)
+			if free < 0 {
+				free = 0
+			}
+			atomic.AddUint64(&hooks.dropped, uint64(len(queued)-free))
+			queued = queued[:free]
+			if h.onOverflow != nil && !h.isOverflowed && !h.stopped {
+				// The hooks are locked, onOverflow may remove the hook
+				h.isOverflowed = true
+				go h.onOverflow()
+			}
+		}
+		h.queue = append(h.queue, queued...)
+		h.mutex.Unlock()
+		h.cond.Signal()
+	}
+}
+
+// run delivers the queued changes to the hook until it is stopped
+func (h *changeHook) run(onStop func()) {
+	if onStop != nil {
+		defer onStop()
+	}
+
+	for {
+		h.mutex.Lock()
+		for len(h.queue) == 0 && !h.stopped {
+			h.cond.Wait()
+		}
+		if h.stopped {
+			h.mutex.Unlock()
+			return
+		}
+		var change = h.queue[0]
+		h.queue = h.queue[1:]
+		h.mutex.Unlock()
+
+		h.hook(change, h.done)
+	}
+}
+
+// stop stops the goroutine of the hook
+func (h *changeHook) stop() {
+	h.mutex.Lock()
+	if !h.stopped {
+		h.stopped = true
+		h.queue = nil
+		close(h.done)
+	}
+	h.mutex.Unlock()
+	h.cond.Signal()
+}
+
+// elementsOf returns the elements of a list
+func elementsOf(elementList ElementList) []Element {
+	var elements []Element
+	for i := 0; i < elementList.Size(); i++ {
+		elements = append(elements, elementList.GetElementAt(i))
+	}
+	return elements
+}
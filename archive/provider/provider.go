@@ -26,6 +26,7 @@ package provider
 import (
 	"errors"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	. "github.com/ccsdsmo/malgo/com"
@@ -35,6 +36,7 @@ import (
 
 	. "github.com/etiennelndr/archiveservice/archive/constants"
 	arch "github.com/etiennelndr/archiveservice/archive/storage"
+	"github.com/etiennelndr/archiveservice/archive/utils"
 	. "github.com/etiennelndr/archiveservice/data"
 	. "github.com/etiennelndr/archiveservice/errors"
 )
@@ -44,10 +46,17 @@ type Provider struct {
 	ctx     *Context
 	cctx    *ClientContext
 	factory EncodingFactory
+	backend arch.ArchiveBackend
+	events  *eventPublisher
+	hooks   *changeHooks
+	queries *standingQueries
+	// maxObjectsPerUpdate is the maximum number of objects sent in
+	// each message of the Query operation (0 means no limit)
+	maxObjectsPerUpdate int64
 }
 
 // Create a provider
-func createProvider(url string) (*Provider, error) {
+func createProvider(url string, backend arch.ArchiveBackend) (*Provider, error) {
 	ctx, err := NewContext(url)
 	if err != nil {
 		return nil, err
@@ -60,15 +69,15 @@ func createProvider(url string) (*Provider, error) {
 
 	factory := new(FixedBinaryEncoding)
 
-	provider := &Provider{ctx, cctx, factory}
+	provider := &Provider{ctx, cctx, factory, backend, new(eventPublisher), newChangeHooks(), newStandingQueries(), DEFAULT_MAX_OBJECTS_PER_UPDATE}
 
 	return provider, nil
 }
 
 // StartProvider : TODO:
-func StartProvider(url string) (*Provider, error) {
+func StartProvider(url string, backend arch.ArchiveBackend) (*Provider, error) {
 	// Create the provider
-	provider, err := createProvider(url)
+	provider, err := createProvider(url, backend)
 	if err != nil {
 		return nil, err
 	}
@@ -109,12 +118,37 @@ func StartProvider(url string) (*Provider, error) {
 		return nil, err
 	}
 
+	// Create and launch the StandingQuery handler
+	err = provider.standingQueryHandler()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create and launch the CancelStandingQuery handler
+	err = provider.cancelStandingQueryHandler()
+	if err != nil {
+		return nil, err
+	}
+
 	return provider, nil
 }
 
-// Close : Allow to close the context of a specific provider
+// SetMaxObjectsPerUpdate : Set the maximum number of objects sent in each
+// message of the Query operation, a bigger group of objects is split across
+// several updates (0 means no limit). Only the groups sent to a consumer which
+// asks for indexed updates are split, it can put them back together
+func (provider *Provider) SetMaxObjectsPerUpdate(maxObjects int) {
+	atomic.StoreInt64(&provider.maxObjectsPerUpdate, int64(maxObjects))
+}
+
+// Close : Allow to close the context of a specific provider and
+// the backend of its archive
 func (provider *Provider) Close() {
+	provider.StopEventPublisher()
+	provider.closeStandingQueries()
+	provider.hooks.removeAll()
 	provider.ctx.Close()
+	provider.backend.Close()
 }
 
 //======================================================================//
@@ -153,7 +187,7 @@ func (provider *Provider) retrieveHandler() error {
 			longList)*/
 
 			// Retrieve these objects in the archive
-			archiveDetailsList, elementList, err := arch.RetrieveInArchive(*objectType, *identifierList, *longList)
+			archiveDetailsList, elementList, err := provider.retrieveObjects(*objectType, *identifierList, *longList)
 			if err != nil {
 				if err.Error() == string(MAL_ERROR_UNKNOWN_MESSAGE) {
 					provider.retrieveResponseError(transaction, MAL_ERROR_UNKNOWN, MAL_ERROR_UNKNOWN_MESSAGE, NewLongList(0))
@@ -164,7 +198,7 @@ func (provider *Provider) retrieveHandler() error {
 			}
 
 			// ----- Call Response operation -----
-			err = provider.retrieveResponse(transaction, &archiveDetailsList, elementList)
+			err = provider.retrieveResponse(transaction, archiveDetailsList, elementList)
 			if err != nil {
 				provider.retrieveResponseError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE, NewLongList(0))
 				return err
@@ -206,6 +240,31 @@ func (provider *Provider) retrieveVerifyParameters(transaction InvokeTransaction
 	return nil
 }
 
+// retrieveObjects reads the objects to retrieve with the cursor of the
+// backend. They are all sent in the single response of the operation, so
+// the memory used by a Retrieve isn't bounded: it holds all its objects
+func (provider *Provider) retrieveObjects(objectType ObjectType, identifierList IdentifierList, longList LongList) (*ArchiveDetailsList, ElementList, error) {
+	cursor, err := provider.backend.RetrieveInArchive(objectType, identifierList, longList)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close()
+
+	archiveDetailsList, elementList, err := readObjects(cursor, objectType, true, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if archiveDetailsList == nil {
+		// The response always contains the lists, even if they are empty
+		archiveDetailsList = NewArchiveDetailsList(0)
+		elementList, err = utils.NewElementList(objectType)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return archiveDetailsList, elementList, nil
+}
+
 // INVOKE : TODO:
 func (provider *Provider) retrieveInvoke(msg *Message) (*ObjectType, *IdentifierList, *LongList, error) {
 	decoder := provider.factory.NewDecoder(msg.Body)
@@ -300,6 +359,42 @@ func (provider *Provider) retrieveResponseError(transaction InvokeTransaction, e
 	return nil
 }
 
+// readObjects reads the objects of a cursor (of the current group for the
+// Query operation) in lists, at most maxObjects of them if it isn't 0. The
+// lists are nil when there isn't any object, and the element list is a nil
+// LongList when the elements aren't requested
+func readObjects(cursor arch.ArchiveCursor, objectType ObjectType, isElementRequested bool, maxObjects int) (*ArchiveDetailsList, ElementList, error) {
+	var archiveDetailsList *ArchiveDetailsList
+	var elementList ElementList
+	var longList *LongList
+	elementList = longList
+
+	for (maxObjects == 0 || archiveDetailsList.Size() < maxObjects) && cursor.Next() {
+		archiveDetails, element := cursor.Object()
+		if archiveDetailsList == nil {
+			archiveDetailsList = NewArchiveDetailsList(0)
+			if isElementRequested {
+				// The list holds the type of the elements read, it isn't
+				// always the element of the same number as the object type
+				var err error
+				elementList, err = utils.NewElementListFor(objectType, element)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		archiveDetailsList.AppendElement(archiveDetails)
+		if isElementRequested {
+			elementList.AppendElement(element)
+		}
+	}
+	if cursor.Err() != nil {
+		return nil, nil, cursor.Err()
+	}
+
+	return archiveDetailsList, elementList, nil
+}
+
 //======================================================================//
 //								QUERY									//
 //======================================================================//
@@ -310,7 +405,7 @@ func (provider *Provider) queryHandler() error {
 			transaction := t.(ProgressTransaction)
 
 			// ----- Retrieve the objects thanks to the progress operation -----
-			boolean, objectType, archiveQueryList, queryFilterList, err := provider.queryProgress(msg)
+			boolean, objectType, archiveQueryList, queryFilterList, isIndexed, err := provider.queryProgress(msg)
 			if err != nil {
 				provider.queryAckError(transaction, MAL_ERROR_BAD_ENCODING, MAL_ERROR_BAD_ENCODING_MESSAGE, NewLongList(0))
 				return err
@@ -323,86 +418,33 @@ func (provider *Provider) queryHandler() error {
 			}
 
 			// ----- Call Ack operation -----
-			err = provider.queryAck(transaction)
+			err = provider.queryAck(transaction, isIndexed)
 			if err != nil {
 				provider.queryAckError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE, NewLongList(0))
 				return err
 			}
 
-			// Variables to send to the consumer
-			var objType []*ObjectType
-			var archDetList []*ArchiveDetailsList
-			var idList []*IdentifierList
-			var elementList []ElementList
-
 			/*fmt.Println("QueryHandler received:\n\t>>>",
 			boolean, "\n\t>>>",
 			objectType, "\n\t>>>",
 			archiveQueryList, "\n\t>>>",
 			queryFilterList)*/
 
-			for i := 0; i < archiveQueryList.Size()-1; i++ {
-				// Do a query to the archive
+			for i := 0; i < archiveQueryList.Size(); i++ {
+				var queryFilter QueryFilter
 				if queryFilterList != nil {
-					objType, archDetList, idList, elementList, err = arch.QueryArchive(boolean, *objectType, *(*archiveQueryList)[i], queryFilterList.GetElementAt(i))
-				} else {
-					objType, archDetList, idList, elementList, err = arch.QueryArchive(boolean, *objectType, *(*archiveQueryList)[i], nil)
+					queryFilter = queryFilterList.GetElementAt(i)
 				}
+				// Do a query to the archive
+				cursor, err := provider.backend.QueryArchive(boolean, *objectType, *(*archiveQueryList)[i], queryFilter)
 				if err != nil {
-					// Send an INVALID error
-					if err.Error() == string(ARCHIVE_SERVICE_QUERY_SORT_FIELD_NAME_INVALID_ERROR) ||
-						err.Error() == string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR) ||
-						strings.Contains(err.Error(), string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR)) {
-						provider.queryUpdateError(transaction, COM_ERROR_INVALID, String(err.Error()), NewLongList(0))
-					}
-					// Otherwise, send an INTERNAL error
-					provider.queryUpdateError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
+					provider.queryError(transaction, err)
 					return err
 				}
-				for j := 0; j < len(archDetList); j++ {
-					// Call Update operation
-					err = provider.queryUpdate(transaction, objType[j], idList[j], archDetList[j], elementList[j])
-					if err != nil {
-						// Send an INTERNAL error
-						provider.queryUpdateError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
-						return err
-					}
-				}
-			}
-
-			// Do a query to the archive
-			if queryFilterList != nil {
-				objType, archDetList, idList, elementList, err = arch.QueryArchive(boolean, *objectType, *(*archiveQueryList)[archiveQueryList.Size()-1], queryFilterList.GetElementAt(archiveQueryList.Size()-1))
-			} else {
-				objType, archDetList, idList, elementList, err = arch.QueryArchive(boolean, *objectType, *(*archiveQueryList)[archiveQueryList.Size()-1], nil)
-			}
-			if err != nil {
-				// Send an INVALID error
-				if err.Error() == string(ARCHIVE_SERVICE_QUERY_SORT_FIELD_NAME_INVALID_ERROR) ||
-					strings.Contains(err.Error(), string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR)) {
-					provider.queryUpdateError(transaction, COM_ERROR_INVALID, String(err.Error()), NewLongList(0))
-				}
-				// Otherwise, send an INTERNAL error
-				provider.queryUpdateError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
-				return err
-			}
-			// ----- Call Response operation -----
-			// Unless archive query list size is equal to 1 (we didn't enter in the previous loop)
-			for j := 0; j < len(archDetList); j++ {
-				if j == len(archDetList)-1 {
-					// Call Response operation
-					err = provider.queryResponse(transaction, objType[j], idList[j], archDetList[j], elementList[j])
-					if err != nil {
-						// Send an INTERNAL error
-						provider.queryResponseError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
-						return err
-					}
-					break
-				}
-				err = provider.queryUpdate(transaction, objType[j], idList[j], archDetList[j], elementList[j])
+				// The last group of the last query is sent with the Response operation
+				err = provider.sendQueryGroups(transaction, cursor, *objectType, boolean != nil && *boolean == true, isIndexed, i, i == archiveQueryList.Size()-1)
+				cursor.Close()
 				if err != nil {
-					// Send an INTERNAL error
-					provider.queryUpdateError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
 					return err
 				}
 			}
@@ -424,6 +466,97 @@ func (provider *Provider) queryHandler() error {
 	return nil
 }
 
+// sendQueryGroups sends the groups of a query one after another as they are
+// read with the cursor. If the consumer has asked for indexed updates, a group
+// bigger than the maximum number of objects per update is split in several
+// updates with the same object type and domain, and only one part of a group
+// is held in memory at a time. Each update then also holds a Boolean (true if
+// the next update holds the next part of the same group) and the index of the
+// archive query. A consumer of the specification receives each group in one
+// update, the memory used isn't bounded then: it holds the biggest group. The
+// last group of the last query is sent with the response
+func (provider *Provider) sendQueryGroups(transaction ProgressTransaction, cursor arch.QueryCursor, objectType ObjectType, isElementRequested bool, isIndexed bool, queryIndex int, isLastQuery bool) error {
+	var maxObjects = 0
+	if isIndexed {
+		maxObjects = int(atomic.LoadInt64(&provider.maxObjectsPerUpdate))
+	}
+	var hasGroup = cursor.NextGroup()
+	for hasGroup {
+		objType, idList := cursor.Group()
+		var elementListType = objectType
+		if objType != nil {
+			elementListType = *objType
+		}
+		archDetList, elementList, err := readObjects(cursor, elementListType, isElementRequested, maxObjects)
+		if err != nil {
+			provider.queryError(transaction, err)
+			return err
+		}
+
+		// Send the full parts of the group while other objects follow them
+		for maxObjects != 0 && archDetList.Size() == maxObjects {
+			nextArchDetList, nextElementList, err := readObjects(cursor, elementListType, isElementRequested, maxObjects)
+			if err != nil {
+				provider.queryError(transaction, err)
+				return err
+			}
+			if nextArchDetList == nil {
+				break
+			}
+
+			// Call Update operation
+			err = provider.queryUpdate(transaction, objType, idList, archDetList, elementList, isIndexed, true, queryIndex)
+			if err != nil {
+				// Send an INTERNAL error
+				provider.queryUpdateError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
+				return err
+			}
+			archDetList, elementList = nextArchDetList, nextElementList
+		}
+
+		hasGroup = cursor.NextGroup()
+		if cursor.Err() != nil {
+			break
+		}
+		if !hasGroup && isLastQuery {
+			// Call Response operation
+			err = provider.queryResponse(transaction, objType, idList, archDetList, elementList)
+			if err != nil {
+				// Send an INTERNAL error
+				provider.queryResponseError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
+				return err
+			}
+			return nil
+		}
+		// Call Update operation
+		err = provider.queryUpdate(transaction, objType, idList, archDetList, elementList, isIndexed, false, queryIndex)
+		if err != nil {
+			// Send an INTERNAL error
+			provider.queryUpdateError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
+			return err
+		}
+	}
+
+	if cursor.Err() != nil {
+		provider.queryError(transaction, cursor.Err())
+		return cursor.Err()
+	}
+	return nil
+}
+
+// queryError sends the error raised by a query to the consumer
+func (provider *Provider) queryError(transaction ProgressTransaction, err error) {
+	if err.Error() == string(ARCHIVE_SERVICE_QUERY_SORT_FIELD_NAME_INVALID_ERROR) ||
+		err.Error() == string(ARCHIVE_SERVICE_QUERY_DOMAIN_NULL_IDENTIFIER_ERROR) ||
+		strings.Contains(err.Error(), string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR)) {
+		// Send an INVALID error
+		provider.queryUpdateError(transaction, COM_ERROR_INVALID, String(err.Error()), NewLongList(0))
+	} else {
+		// Otherwise, send an INTERNAL error
+		provider.queryUpdateError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
+	}
+}
+
 // VERIFY PARAMETERS : TODO:
 func (provider *Provider) queryVerifyParameters(transaction ProgressTransaction, archiveQueryList *ArchiveQueryList, queryFilterList QueryFilterList) error {
 	if queryFilterList != nil && archiveQueryList.Size() != queryFilterList.Size() {
@@ -431,48 +564,81 @@ func (provider *Provider) queryVerifyParameters(transaction ProgressTransaction,
 		return errors.New(string(ARCHIVE_SERVICE_QUERY_LISTS_SIZE_ERROR))
 	}
 
+	// The domains can't contain NULL identifiers, the extra information
+	// holds the index of the wrong archive query
+	for i, archiveQuery := range *archiveQueryList {
+		if archiveQuery.Domain != nil && utils.HasNullIdentifier(*archiveQuery.Domain) {
+			provider.queryAckError(transaction, COM_ERROR_INVALID, ARCHIVE_SERVICE_QUERY_DOMAIN_NULL_IDENTIFIER_ERROR, &LongList{NewLong(int64(i))})
+			return errors.New(string(ARCHIVE_SERVICE_QUERY_DOMAIN_NULL_IDENTIFIER_ERROR))
+		}
+	}
+
 	return nil
 }
 
-// PROGRESS : TODO:
-func (provider *Provider) queryProgress(msg *Message) (*Boolean, *ObjectType, *ArchiveQueryList, QueryFilterList, error) {
+// PROGRESS : Decode the parameters of the Query operation. The returned
+// boolean is true if the consumer asks for indexed updates, with a Boolean
+// set to true after the parameters (a local extension of the operation)
+func (provider *Provider) queryProgress(msg *Message) (*Boolean, *ObjectType, *ArchiveQueryList, QueryFilterList, bool, error) {
 	// Create the decoder
 	decoder := provider.factory.NewDecoder(msg.Body)
 
 	// Decode Boolean
 	boolean, err := decoder.DecodeNullableElement(NullBoolean)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, nil, nil, nil, false, err
 	}
 
 	// Decode ObjectType
 	objectType, err := decoder.DecodeElement(NullObjectType)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, nil, nil, nil, false, err
 	}
 
 	// Decode ArchiveQueryList
 	archiveQueryList, err := decoder.DecodeElement(NullArchiveQueryList)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, nil, nil, nil, false, err
 	}
 
 	// Decode QueryFilterList
 	queryFilterList, err := decoder.DecodeNullableAbstractElement()
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, nil, nil, nil, false, err
 	}
+
+	// Decode Boolean, a consumer of the specification doesn't send it
+	var isIndexed = false
+	indexed, err := decoder.DecodeNullableElement(NullBoolean)
+	if err == nil && indexed != nil {
+		isIndexed = bool(*indexed.(*Boolean))
+	}
+
 	if queryFilterList == nil {
-		return boolean.(*Boolean), objectType.(*ObjectType), archiveQueryList.(*ArchiveQueryList), nil, nil
+		return boolean.(*Boolean), objectType.(*ObjectType), archiveQueryList.(*ArchiveQueryList), nil, isIndexed, nil
 	}
 
-	return boolean.(*Boolean), objectType.(*ObjectType), archiveQueryList.(*ArchiveQueryList), queryFilterList.(QueryFilterList), nil
+	return boolean.(*Boolean), objectType.(*ObjectType), archiveQueryList.(*ArchiveQueryList), queryFilterList.(QueryFilterList), isIndexed, nil
 }
 
-// ACK : TODO:
-func (provider *Provider) queryAck(transaction ProgressTransaction) error {
+// ACK : Acknowledge the Query operation. If the updates are indexed, the
+// Ack holds a Boolean set to true so the consumer knows their layout
+func (provider *Provider) queryAck(transaction ProgressTransaction, isIndexed bool) error {
+	var body []byte
+	if isIndexed {
+		// Create the encoder
+		encoder := provider.factory.NewEncoder(make([]byte, 0, LENGTH))
+
+		// Encode Boolean
+		err := NewBoolean(true).Encode(encoder)
+		if err != nil {
+			return err
+		}
+		body = encoder.Body()
+	}
+
 	// Call Ack operation
-	err := transaction.Ack(nil, false)
+	err := transaction.Ack(body, false)
 	if err != nil {
 		return err
 	}
@@ -498,8 +664,11 @@ func (provider *Provider) queryAckError(transaction ProgressTransaction, errorNu
 	return nil
 }
 
-// UPDATE : TODO:
-func (provider *Provider) queryUpdate(transaction ProgressTransaction, objectType *ObjectType, identifierList *IdentifierList, archiveDetailsList *ArchiveDetailsList, elementList ElementList) error {
+// UPDATE : Send a group of objects selected by an archive query, or a part
+// of it. The update has the layout of the specification, unless it is
+// indexed: isContinued (true if the next update holds the next objects of
+// the same group) and the index of the archive query are then added
+func (provider *Provider) queryUpdate(transaction ProgressTransaction, objectType *ObjectType, identifierList *IdentifierList, archiveDetailsList *ArchiveDetailsList, elementList ElementList, isIndexed bool, isContinued bool, queryIndex int) error {
 	// Create the encoder
 	encoder := provider.factory.NewEncoder(make([]byte, 0, LENGTH))
 
@@ -527,6 +696,20 @@ func (provider *Provider) queryUpdate(transaction ProgressTransaction, objectTyp
 		return err
 	}
 
+	if isIndexed {
+		// Encode Boolean
+		err = NewBoolean(isContinued).Encode(encoder)
+		if err != nil {
+			return err
+		}
+
+		// Encode UInteger
+		err = NewUInteger(uint32(queryIndex)).Encode(encoder)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Call Update operation
 	err = transaction.Update(encoder.Body(), false)
 	if err != nil {
@@ -647,10 +830,11 @@ func (provider *Provider) countHandler() error {
 			queryFilterList)*/
 
 			// This variable will be created automatically in the future
-			longList, err := arch.CountInArchive(*objectType, *archiveQueryList, queryFilterList)
+			longList, err := provider.backend.CountInArchive(*objectType, *archiveQueryList, queryFilterList)
 			if err != nil {
 				// Send an INVALID error
 				if err.Error() == string(ARCHIVE_SERVICE_QUERY_SORT_FIELD_NAME_INVALID_ERROR) ||
+					err.Error() == string(ARCHIVE_SERVICE_QUERY_DOMAIN_NULL_IDENTIFIER_ERROR) ||
 					strings.Contains(err.Error(), string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR)) {
 					provider.countResponseError(transaction, COM_ERROR_INVALID, String(err.Error()), NewLongList(0))
 				}
@@ -689,6 +873,15 @@ func (provider *Provider) countVerifyParameters(transaction InvokeTransaction, a
 		return errors.New(string(ARCHIVE_SERVICE_QUERY_LISTS_SIZE_ERROR))
 	}
 
+	// The domains can't contain NULL identifiers, the extra information
+	// holds the index of the wrong archive query
+	for i, archiveQuery := range *archiveQueryList {
+		if archiveQuery.Domain != nil && utils.HasNullIdentifier(*archiveQuery.Domain) {
+			provider.countAckError(transaction, COM_ERROR_INVALID, ARCHIVE_SERVICE_QUERY_DOMAIN_NULL_IDENTIFIER_ERROR, &LongList{NewLong(int64(i))})
+			return errors.New(string(ARCHIVE_SERVICE_QUERY_DOMAIN_NULL_IDENTIFIER_ERROR))
+		}
+	}
+
 	return nil
 }
 
@@ -818,9 +1011,10 @@ func (provider *Provider) storeHandler() error {
 			archiveDetailsList, "\n\t>>>",
 			elementList)*/
 
-			// Store these objects in the archive
+			// Store these objects in the archive, the object instance identifiers
+			// are always needed to publish the events
 			var longList *LongList
-			longList, err = arch.StoreInArchive(boolean, *objectType, *identifierList, *archiveDetailsList, elementList)
+			longList, err = provider.backend.StoreInArchive(NewBoolean(true), *objectType, *identifierList, *archiveDetailsList, elementList)
 			if err != nil {
 				if err.Error() == string(COM_ERROR_DUPLICATE) {
 					provider.storeResponseError(transaction, COM_ERROR_DUPLICATE, COM_ERROR_DUPLICATE_MESSAGE, NewLongList(0))
@@ -830,8 +1024,18 @@ func (provider *Provider) storeHandler() error {
 				return err
 			}
 
-			// TODO: for each object stored, an 'ObjectStored' event may be published
-			// to the event service
+			// Set the object instance identifiers of the stored objects
+			var storedArchiveDetailsList = *NewArchiveDetailsList(0)
+			for i, archiveDetails := range *archiveDetailsList {
+				storedArchiveDetails := *archiveDetails
+				storedArchiveDetails.InstId = *(*longList)[i]
+				storedArchiveDetailsList.AppendElement(&storedArchiveDetails)
+			}
+
+			// The object instance identifiers are only returned if the boolean is true
+			if boolean == nil || !*boolean {
+				longList = nil
+			}
 
 			// Call Response operation
 			err = provider.storeResponse(transaction, longList)
@@ -839,6 +1043,15 @@ func (provider *Provider) storeHandler() error {
 				provider.storeResponseError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
 				return err
 			}
+
+			// Inform the hooks of the stored objects
+			provider.hooks.notify(CHANGE_TYPE_STORE, *objectType, *identifierList, storedArchiveDetailsList, elementsOf(elementList))
+
+			// For each object stored, an 'ObjectStored' event is published
+			err = provider.publishEvents(ARCHIVE_SERVICE_EVENT_OBJECT_STORED, UPDATETYPE_CREATION, *objectType, *identifierList, storedArchiveDetailsList)
+			if err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -1004,7 +1217,7 @@ func (provider *Provider) updateHandler() error {
 			elementList)*/
 
 			// Update these objects
-			err = arch.UpdateArchive(*objectType, *identifierList, *archiveDetailsList, elementList)
+			err = provider.backend.UpdateArchive(*objectType, *identifierList, *archiveDetailsList, elementList)
 			if err != nil {
 				if err.Error() == string(MAL_ERROR_UNKNOWN_MESSAGE) {
 					provider.updateAckError(transaction, MAL_ERROR_UNKNOWN, ARCHIVE_SERVICE_UNKNOWN_ELEMENT, NewLongList(0))
@@ -1014,15 +1227,21 @@ func (provider *Provider) updateHandler() error {
 				return err
 			}
 
-			// TODO: for each object updated, an 'ObjectUpdated' event may be published
-			// to the event service
-
 			// Call Ack operation
 			err = provider.updateAck(transaction)
 			if err != nil {
 				provider.updateAckError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
 				return err
 			}
+
+			// Inform the hooks of the updated objects
+			provider.hooks.notify(CHANGE_TYPE_UPDATE, *objectType, *identifierList, *archiveDetailsList, elementsOf(elementList))
+
+			// For each object updated, an 'ObjectUpdated' event is published
+			err = provider.publishEvents(ARCHIVE_SERVICE_EVENT_OBJECT_UPDATED, UPDATETYPE_UPDATE, *objectType, *identifierList, *archiveDetailsList)
+			if err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -1156,8 +1375,8 @@ func (provider *Provider) deleteHandler() error {
 			identifierList, "\n\t>>>",
 			longListRequest)*/
 
-			// Delete these objects
-			longListResponse, err := arch.DeleteInArchive(*objectType, *identifierList, *longListRequest)
+			// Delete these objects, the backend returns them for the events and the hooks
+			longListResponse, cursor, err := provider.backend.DeleteInArchive(*objectType, *identifierList, *longListRequest)
 			if err != nil {
 				if err.Error() == string(MAL_ERROR_UNKNOWN_MESSAGE) {
 					provider.deleteResponseError(transaction, MAL_ERROR_UNKNOWN, ARCHIVE_SERVICE_UNKNOWN_ELEMENT, NewLongList(0))
@@ -1166,9 +1385,7 @@ func (provider *Provider) deleteHandler() error {
 				}
 				return err
 			}
-
-			// TODO: for each object deleted, an 'ObjectDeleted' event may be published
-			// to the event service
+			defer cursor.Close()
 
 			// Call Response operation
 			err = provider.deleteResponse(transaction, longListResponse)
@@ -1176,6 +1393,20 @@ func (provider *Provider) deleteHandler() error {
 				provider.deleteResponseError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
 				return err
 			}
+
+			// Inform the hooks of the deleted objects, even if some of them
+			// couldn't be read (the error is returned afterwards)
+			deletedArchiveDetailsList, deletedElements, readErr := deletedObjects(cursor, longListResponse)
+			provider.hooks.notify(CHANGE_TYPE_DELETE, *objectType, *identifierList, deletedArchiveDetailsList, deletedElements)
+
+			// For each object deleted, an 'ObjectDeleted' event is published
+			err = provider.publishEvents(ARCHIVE_SERVICE_EVENT_OBJECT_DELETED, UPDATETYPE_DELETION, *objectType, *identifierList, deletedArchiveDetailsList)
+			if err != nil {
+				return err
+			}
+			if readErr != nil {
+				return readErr
+			}
 		}
 
 		return nil
@@ -0,0 +1,382 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package provider
+
+import (
+	"errors"
+	"sync"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+	. "github.com/ccsdsmo/malgo/mal/api"
+
+	. "github.com/etiennelndr/archiveservice/archive/constants"
+	arch "github.com/etiennelndr/archiveservice/archive/storage"
+	"github.com/etiennelndr/archiveservice/archive/utils"
+	. "github.com/etiennelndr/archiveservice/data"
+)
+
+// standingQuery is a query registered by a consumer: an update is sent
+// with its transaction for each object stored or updated which matches it,
+// until the consumer cancels it
+type standingQuery struct {
+	mutex              sync.Mutex
+	transaction        ProgressTransaction
+	objectType         ObjectType
+	archiveQuery       ArchiveQuery
+	queryFilter        QueryFilter
+	isElementRequested bool
+	remove             func()
+	closed             bool
+}
+
+// standingQueries holds the standing queries of a provider
+type standingQueries struct {
+	mutex   sync.Mutex
+	lastID  Long
+	queries map[Long]*standingQuery
+}
+
+// Create the standing queries of a provider
+func newStandingQueries() *standingQueries {
+	return &standingQueries{queries: make(map[Long]*standingQuery)}
+}
+
+// add registers a standing query and returns its identifier
+func (queries *standingQueries) add(query *standingQuery) Long {
+	queries.mutex.Lock()
+	defer queries.mutex.Unlock()
+
+	queries.lastID++
+	queries.queries[queries.lastID] = query
+	return queries.lastID
+}
+
+// remove unregisters a standing query, nil is returned if it doesn't exist
+func (queries *standingQueries) remove(id Long) *standingQuery {
+	queries.mutex.Lock()
+	defer queries.mutex.Unlock()
+
+	query, ok := queries.queries[id]
+	if !ok {
+		return nil
+	}
+	delete(queries.queries, id)
+	return query
+}
+
+// removeAll unregisters all the standing queries, when the provider is closed
+func (queries *standingQueries) removeAll() []*standingQuery {
+	queries.mutex.Lock()
+	defer queries.mutex.Unlock()
+
+	var removed []*standingQuery
+	for id, query := range queries.queries {
+		delete(queries.queries, id)
+		removed = append(removed, query)
+	}
+	return removed
+}
+
+// closeStandingQueries ends all the standing queries of the provider
+func (provider *Provider) closeStandingQueries() {
+	for _, query := range provider.queries.removeAll() {
+		provider.standingQueryClose(query)
+	}
+}
+
+//======================================================================//
+//							STANDING QUERY								//
+//======================================================================//
+// Create a handler for the standing query operation
+func (provider *Provider) standingQueryHandler() error {
+	standingQueryHandler := func(msg *Message, t Transaction) error {
+		if msg != nil {
+			transaction := t.(ProgressTransaction)
+
+			// ----- Retrieve the query thanks to the progress operation -----
+			boolean, objectType, archiveQuery, queryFilter, err := provider.standingQueryProgress(msg)
+			if err != nil {
+				provider.queryAckError(transaction, MAL_ERROR_BAD_ENCODING, MAL_ERROR_BAD_ENCODING_MESSAGE, NewLongList(0))
+				return err
+			}
+
+			// ----- Verify the parameters -----
+			err = arch.VerifyQuery(*objectType, *archiveQuery, queryFilter)
+			if err != nil {
+				provider.queryAckError(transaction, COM_ERROR_INVALID, String(err.Error()), NewLongList(0))
+				return err
+			}
+
+			query := &standingQuery{
+				transaction:        transaction,
+				objectType:         *objectType,
+				archiveQuery:       *archiveQuery,
+				queryFilter:        queryFilter,
+				isElementRequested: boolean != nil && *boolean == true,
+			}
+			id := provider.queries.add(query)
+
+			// The lock prevents an update from being sent before the Ack
+			query.mutex.Lock()
+			defer query.mutex.Unlock()
+
+			// ----- Call Ack operation with the identifier of the standing query -----
+			err = provider.standingQueryAck(transaction, id)
+			if err != nil {
+				provider.queries.remove(id)
+				provider.queryAckError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE, NewLongList(0))
+				return err
+			}
+
+			// Select the objects as they are stored or updated
+			query.remove = provider.hooks.add(func(change Change, done <-chan struct{}) {
+				err := provider.standingQueryUpdate(query, change)
+				if err != nil {
+					// The consumer can't receive the objects anymore, or
+					// has received an update error which ended the query
+					if provider.queries.remove(id) != nil {
+						provider.standingQueryClose(query)
+					}
+				}
+			}, nil, func() {
+				// A dropped change may have matched the standing query, the
+				// consumer is told that objects are missing and the query ends
+				if provider.queries.remove(id) != nil {
+					provider.standingQueryError(query, MAL_ERROR_INTERNAL, ARCHIVE_SERVICE_STANDING_QUERY_OVERFLOW_ERROR, nil)
+				}
+			})
+		}
+
+		return nil
+	}
+
+	// Register the handler
+	err := provider.cctx.RegisterProgressHandler(COM_AREA_NUMBER,
+		COM_AREA_VERSION,
+		ARCHIVE_SERVICE_SERVICE_NUMBER,
+		OPERATION_IDENTIFIER_STANDING_QUERY,
+		standingQueryHandler)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PROGRESS : Decode the parameters of a standing query
+func (provider *Provider) standingQueryProgress(msg *Message) (*Boolean, *ObjectType, *ArchiveQuery, QueryFilter, error) {
+	// Create the decoder
+	decoder := provider.factory.NewDecoder(msg.Body)
+
+	// Decode Boolean
+	boolean, err := decoder.DecodeNullableElement(NullBoolean)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// Decode ObjectType
+	objectType, err := decoder.DecodeElement(NullObjectType)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// Decode ArchiveQuery
+	archiveQuery, err := decoder.DecodeElement(NullArchiveQuery)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// Decode QueryFilter
+	queryFilter, err := decoder.DecodeNullableAbstractElement()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if queryFilter == nil {
+		return boolean.(*Boolean), objectType.(*ObjectType), archiveQuery.(*ArchiveQuery), nil, nil
+	}
+
+	return boolean.(*Boolean), objectType.(*ObjectType), archiveQuery.(*ArchiveQuery), queryFilter.(QueryFilter), nil
+}
+
+// ACK : Send the identifier of the standing query to the consumer
+func (provider *Provider) standingQueryAck(transaction ProgressTransaction, id Long) error {
+	// Create the encoder
+	encoder := provider.factory.NewEncoder(make([]byte, 0, LENGTH))
+
+	// Encode Long
+	err := id.Encode(encoder)
+	if err != nil {
+		return err
+	}
+
+	// Call Ack operation
+	err = transaction.Ack(encoder.Body(), false)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UPDATE : Send a stored or updated object if it matches the standing query
+func (provider *Provider) standingQueryUpdate(query *standingQuery, change Change) error {
+	if change.Type == CHANGE_TYPE_DELETE || change.ArchiveDetails == nil {
+		return nil
+	}
+
+	isMatching, err := arch.MatchObject(change.ObjectType, change.Domain, *change.ArchiveDetails, change.Element,
+		query.objectType, query.archiveQuery, query.queryFilter)
+	if err != nil {
+		// The filter can't be applied to the object (e.g. the body of an
+		// object of another type doesn't have the fields of the filter)
+		return provider.standingQueryError(query, COM_ERROR_INVALID, String(err.Error()), err)
+	}
+	if !isMatching {
+		return nil
+	}
+
+	var elementList ElementList
+	var longList *LongList
+	elementList = longList
+	if query.isElementRequested {
+		elementList, err = utils.NewElementListFor(change.ObjectType, change.Element)
+		if err != nil {
+			return provider.standingQueryError(query, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), err)
+		}
+		elementList.AppendElement(change.Element)
+	}
+
+	query.mutex.Lock()
+	defer query.mutex.Unlock()
+
+	if query.closed {
+		return nil
+	}
+
+	var objectType = change.ObjectType
+	var identifierList = change.Domain
+	return provider.queryUpdate(query.transaction, &objectType, &identifierList, &ArchiveDetailsList{change.ArchiveDetails}, elementList, false, false, 0)
+}
+
+// UPDATE ERROR : End a standing query with an update error, the consumer
+// receives it instead of the next object. The error of the object is
+// returned, so that the standing query is unregistered
+func (provider *Provider) standingQueryError(query *standingQuery, errorNumber UInteger, errorComment String, err error) error {
+	query.mutex.Lock()
+	defer query.mutex.Unlock()
+
+	if query.closed {
+		return err
+	}
+	query.closed = true
+	if query.remove != nil {
+		query.remove()
+	}
+
+	provider.queryUpdateError(query.transaction, errorNumber, errorComment, NewLongList(0))
+	return err
+}
+
+// RESPONSE : End a standing query, no object is sent to its consumer anymore
+func (provider *Provider) standingQueryClose(query *standingQuery) error {
+	query.mutex.Lock()
+	defer query.mutex.Unlock()
+
+	if query.closed {
+		return nil
+	}
+	query.closed = true
+	if query.remove != nil {
+		query.remove()
+	}
+
+	return provider.queryResponse(query.transaction, nil, nil, nil, nil)
+}
+
+//======================================================================//
+//						CANCEL STANDING QUERY							//
+//======================================================================//
+// Create a handler for the cancel standing query operation
+func (provider *Provider) cancelStandingQueryHandler() error {
+	cancelStandingQueryHandler := func(msg *Message, t Transaction) error {
+		if msg != nil {
+			transaction := t.(SubmitTransaction)
+
+			// Call Submit operation
+			id, err := provider.cancelStandingQuerySubmit(msg)
+			if err != nil {
+				provider.updateAckError(transaction, MAL_ERROR_BAD_ENCODING, MAL_ERROR_BAD_ENCODING_MESSAGE, NewLongList(0))
+				return err
+			}
+
+			query := provider.queries.remove(*id)
+			if query == nil {
+				provider.updateAckError(transaction, MAL_ERROR_UNKNOWN, ARCHIVE_SERVICE_UNKNOWN_STANDING_QUERY, NewLongList(0))
+				return errors.New(string(ARCHIVE_SERVICE_UNKNOWN_STANDING_QUERY))
+			}
+
+			// End the standing query before the Ack, so its consumer
+			// doesn't receive any update after the cancellation
+			err = provider.standingQueryClose(query)
+			if err != nil {
+				provider.updateAckError(transaction, MAL_ERROR_INTERNAL, MAL_ERROR_INTERNAL_MESSAGE+String(" "+err.Error()), NewLongList(0))
+				return err
+			}
+
+			// Call Ack operation
+			err = transaction.Ack(nil, false)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// Register the handler
+	err := provider.cctx.RegisterSubmitHandler(COM_AREA_NUMBER,
+		COM_AREA_VERSION,
+		ARCHIVE_SERVICE_SERVICE_NUMBER,
+		OPERATION_IDENTIFIER_CANCEL_STANDING_QUERY,
+		cancelStandingQueryHandler)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SUBMIT : Decode the identifier of the standing query to cancel
+func (provider *Provider) cancelStandingQuerySubmit(msg *Message) (*Long, error) {
+	// Create the decoder
+	decoder := provider.factory.NewDecoder(msg.Body)
+
+	// Decode Long
+	id, err := decoder.DecodeElement(NullLong)
+	if err != nil {
+		return nil, err
+	}
+
+	return id.(*Long), nil
+}
@@ -25,6 +25,7 @@ package service
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -47,6 +48,7 @@ import (
 	. "github.com/etiennelndr/archiveservice/archive/constants"
 	. "github.com/etiennelndr/archiveservice/archive/consumer"
 	. "github.com/etiennelndr/archiveservice/archive/provider"
+	arch "github.com/etiennelndr/archiveservice/archive/storage"
 	. "github.com/etiennelndr/archiveservice/data"
 
 	. "github.com/etiennelndr/archiveservice/errors"
@@ -61,20 +63,23 @@ type ArchiveService struct {
 	ServiceNumber     Integer
 	AreaVersion       UOctet
 
-	running bool
-	wg      sync.WaitGroup
+	running             bool
+	wg                  sync.WaitGroup
+	brokerURL           string
+	maxObjectsPerUpdate int
 }
 
 // CreateService : TODO:
 func (*ArchiveService) CreateService() Service {
 	archiveService := &ArchiveService{
-		AreaIdentifier:    ARCHIVE_SERVICE_AREA_IDENTIFIER,
-		ServiceIdentifier: ARCHIVE_SERVICE_SERVICE_IDENTIFIER,
-		AreaNumber:        COM_AREA_NUMBER,
-		ServiceNumber:     ARCHIVE_SERVICE_SERVICE_NUMBER,
-		AreaVersion:       COM_AREA_VERSION,
-		running:           true,
-		wg:                *new(sync.WaitGroup),
+		AreaIdentifier:      ARCHIVE_SERVICE_AREA_IDENTIFIER,
+		ServiceIdentifier:   ARCHIVE_SERVICE_SERVICE_IDENTIFIER,
+		AreaNumber:          COM_AREA_NUMBER,
+		ServiceNumber:       ARCHIVE_SERVICE_SERVICE_NUMBER,
+		AreaVersion:         COM_AREA_VERSION,
+		running:             true,
+		wg:                  *new(sync.WaitGroup),
+		maxObjectsPerUpdate: DEFAULT_MAX_OBJECTS_PER_UPDATE,
 	}
 
 	return archiveService
@@ -84,8 +89,15 @@ func (*ArchiveService) CreateService() Service {
 //                          START: Consumer                             //
 //======================================================================//
 
+// NewClient : Create a client of the provider which keeps its context open
+// between the operations, unlike the methods of the ArchiveService which
+// create a new consumer each time. The client must be closed
+func (archiveService *ArchiveService) NewClient(consumerURL string, providerURL string) (*ArchiveClient, error) {
+	return NewArchiveClient(consumerURL, NewURI(providerURL+"/archiveServiceProvider"))
+}
+
 // Retrieve : TODO:
-func (archiveService *ArchiveService) Retrieve(consumerURL string, providerURL string, objectType ObjectType, identifierList IdentifierList, longList LongList) (*ArchiveDetailsList, ElementList, *ServiceError, error) {
+func (archiveService *ArchiveService) Retrieve(ctx context.Context, consumerURL string, providerURL string, objectType ObjectType, identifierList IdentifierList, longList LongList) (*ArchiveDetailsList, ElementList, *ServiceError, error) {
 	// Start Operation
 	// Maybe we should not have to return an error
 	fmt.Println("Creation : Retrieve Consumer")
@@ -93,7 +105,8 @@ func (archiveService *ArchiveService) Retrieve(consumerURL string, providerURL s
 	// IN
 	var providerURI = NewURI(providerURL + "/archiveServiceProvider")
 	// OUT
-	consumer, archiveDetailsList, elementList, errorsList, err := StartRetrieveConsumer(consumerURL,
+	consumer, archiveDetailsList, elementList, errorsList, err := StartRetrieveConsumer(ctx,
+		consumerURL,
 		providerURI,
 		objectType,
 		identifierList,
@@ -110,8 +123,8 @@ func (archiveService *ArchiveService) Retrieve(consumerURL string, providerURL s
 	return archiveDetailsList, elementList, nil, nil
 }
 
-// Query : TODO:
-func (archiveService *ArchiveService) Query(consumerURL string, providerURL string, boolean *Boolean, objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) ([]interface{}, *ServiceError, error) {
+// Query : Retrieve the groups of objects matching a list of archive queries
+func (archiveService *ArchiveService) Query(ctx context.Context, consumerURL string, providerURL string, boolean *Boolean, objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (*QueryResponse, *ServiceError, error) {
 	// Start Operation
 	// Maybe we should not have to return an error
 	fmt.Println("Creation : Query Consumer")
@@ -119,7 +132,8 @@ func (archiveService *ArchiveService) Query(consumerURL string, providerURL stri
 	// IN
 	var providerURI = NewURI(providerURL + "/archiveServiceProvider")
 	// OUT
-	consumer, responses, errorsList, err := StartQueryConsumer(consumerURL,
+	consumer, response, errorsList, err := StartQueryConsumer(ctx,
+		consumerURL,
 		providerURI,
 		boolean,
 		objectType,
@@ -134,11 +148,48 @@ func (archiveService *ArchiveService) Query(consumerURL string, providerURL stri
 	// Close the consumer
 	consumer.Close()
 
-	return responses, nil, nil
+	return response, nil, nil
+}
+
+// QueryStream : Call a function with the objects matching a list of archive
+// queries as soon as they are received, until it returns false. A big group
+// of objects may be received in several parts (see QueryStream.Next)
+func (archiveService *ArchiveService) QueryStream(ctx context.Context, consumerURL string, providerURL string, boolean *Boolean, objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList, f func(result *QueryResult) bool) (*ServiceError, error) {
+	fmt.Println("Creation : Query Stream Consumer")
+
+	// IN
+	var providerURI = NewURI(providerURL + "/archiveServiceProvider")
+	// OUT
+	stream, errorsList, err := StartQueryStream(ctx,
+		consumerURL,
+		providerURI,
+		boolean,
+		objectType,
+		archiveQueryList,
+		queryFilterList)
+	if err != nil {
+		return nil, err
+	} else if errorsList != nil {
+		return errorsList, nil
+	}
+
+	// Close the consumer, the objects not received yet are dropped
+	defer stream.Close()
+
+	for {
+		result, errorsList, err := stream.Next(ctx)
+		if err != nil {
+			return nil, err
+		} else if errorsList != nil {
+			return errorsList, nil
+		} else if result == nil || !f(result) {
+			return nil, nil
+		}
+	}
 }
 
 // Count : TODO:
-func (archiveService *ArchiveService) Count(consumerURL string, providerURL string, objectType *ObjectType, archiveQueryList *ArchiveQueryList, queryFilterList QueryFilterList) (*LongList, *ServiceError, error) {
+func (archiveService *ArchiveService) Count(ctx context.Context, consumerURL string, providerURL string, objectType *ObjectType, archiveQueryList *ArchiveQueryList, queryFilterList QueryFilterList) (*LongList, *ServiceError, error) {
 	// Start Operation
 	// Maybe we should not have to return an error
 	fmt.Println("Creation : Count Consumer")
@@ -146,7 +197,8 @@ func (archiveService *ArchiveService) Count(consumerURL string, providerURL stri
 	// IN
 	var providerURI = NewURI(providerURL + "/archiveServiceProvider")
 	// OUT
-	consumer, longList, errorsList, err := StartCountConsumer(consumerURL,
+	consumer, longList, errorsList, err := StartCountConsumer(ctx,
+		consumerURL,
 		providerURI,
 		objectType,
 		archiveQueryList,
@@ -164,7 +216,7 @@ func (archiveService *ArchiveService) Count(consumerURL string, providerURL stri
 }
 
 // Store : TODO:
-func (archiveService *ArchiveService) Store(consumerURL string, providerURL string, boolean *Boolean, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*LongList, *ServiceError, error) {
+func (archiveService *ArchiveService) Store(ctx context.Context, consumerURL string, providerURL string, boolean *Boolean, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*LongList, *ServiceError, error) {
 	// Start Operation
 	// Maybe we should not have to return an error
 	fmt.Println("Creation : Store Consumer")
@@ -172,7 +224,8 @@ func (archiveService *ArchiveService) Store(consumerURL string, providerURL stri
 	// IN
 	var providerURI = NewURI(providerURL + "/archiveServiceProvider")
 	// OUT
-	consumer, longList, errorsList, err := StartStoreConsumer(consumerURL,
+	consumer, longList, errorsList, err := StartStoreConsumer(ctx,
+		consumerURL,
 		providerURI,
 		boolean,
 		objectType,
@@ -192,7 +245,7 @@ func (archiveService *ArchiveService) Store(consumerURL string, providerURL stri
 }
 
 // Update : TODO:
-func (archiveService *ArchiveService) Update(consumerURL string, providerURL string, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*ServiceError, error) {
+func (archiveService *ArchiveService) Update(ctx context.Context, consumerURL string, providerURL string, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*ServiceError, error) {
 	// Start Operation
 	// Maybe we should not have to return an error
 	fmt.Println("Creation : Update Consumer")
@@ -200,7 +253,8 @@ func (archiveService *ArchiveService) Update(consumerURL string, providerURL str
 	// IN
 	var providerURI = NewURI(providerURL + "/archiveServiceProvider")
 	// OUT
-	consumer, errorsList, err := StartUpdateConsumer(consumerURL,
+	consumer, errorsList, err := StartUpdateConsumer(ctx,
+		consumerURL,
 		providerURI,
 		objectType,
 		identifierList,
@@ -219,7 +273,7 @@ func (archiveService *ArchiveService) Update(consumerURL string, providerURL str
 }
 
 // Delete : TODO:
-func (archiveService *ArchiveService) Delete(consumerURL string, providerURL string, objectType ObjectType, identifierList IdentifierList, longList LongList) (*LongList, *ServiceError, error) {
+func (archiveService *ArchiveService) Delete(ctx context.Context, consumerURL string, providerURL string, objectType ObjectType, identifierList IdentifierList, longList LongList) (*LongList, *ServiceError, error) {
 	// Start Operation
 	// Maybe we should not have to return an error
 	fmt.Println("Creation : Delete Consumer")
@@ -227,7 +281,8 @@ func (archiveService *ArchiveService) Delete(consumerURL string, providerURL str
 	// IN
 	var providerURI = NewURI(providerURL + "/archiveServiceProvider")
 	// OUT
-	consumer, respLongList, errorsList, err := StartDeleteConsumer(consumerURL,
+	consumer, respLongList, errorsList, err := StartDeleteConsumer(ctx,
+		consumerURL,
 		providerURI,
 		objectType,
 		identifierList,
@@ -244,15 +299,53 @@ func (archiveService *ArchiveService) Delete(consumerURL string, providerURL str
 	return respLongList, nil, nil
 }
 
+// StandingQuery : Register a standing query, the consumer returned receives
+// the objects matching it as they are stored or updated. It must be closed
+// to cancel the standing query
+func (archiveService *ArchiveService) StandingQuery(ctx context.Context, consumerURL string, providerURL string, boolean *Boolean, objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) (*StandingQueryConsumer, *ServiceError, error) {
+	fmt.Println("Creation : StandingQuery Consumer")
+
+	// IN
+	var providerURI = NewURI(providerURL + "/archiveServiceProvider")
+	// OUT
+	consumer, errorsList, err := StartStandingQueryConsumer(ctx,
+		consumerURL,
+		providerURI,
+		boolean,
+		objectType,
+		archiveQuery,
+		queryFilter)
+	if err != nil {
+		return nil, nil, err
+	} else if errorsList != nil {
+		return nil, errorsList, nil
+	}
+
+	return consumer, nil, nil
+}
+
 //======================================================================//
 //                          START: Provider                             //
 //======================================================================//
 
+// SetEventBroker : Allow the provider to publish the ObjectStored, ObjectUpdated
+// and ObjectDeleted events to a broker (no event is published by default)
+func (archiveService *ArchiveService) SetEventBroker(brokerURL string) {
+	archiveService.brokerURL = brokerURL
+}
+
+// SetMaxObjectsPerUpdate : Set the maximum number of objects sent by the
+// provider in each message of the Query operation to the consumers which
+// ask for indexed updates (0 means no limit)
+func (archiveService *ArchiveService) SetMaxObjectsPerUpdate(maxObjects int) {
+	archiveService.maxObjectsPerUpdate = maxObjects
+}
+
 // StartProvider : TODO:
-func (archiveService *ArchiveService) StartProvider(providerURL string) error {
+func (archiveService *ArchiveService) StartProvider(providerURL string, backend arch.ArchiveBackend) error {
 	archiveService.wg.Add(2)
 	// Start the retrieve provider
-	go archiveService.launchProvider(providerURL)
+	go archiveService.launchProvider(providerURL, backend)
 	// Start a simple method to stop the providers
 	go archiveService.stopProviders()
 	// Wait until the end of the six operations
@@ -280,7 +373,7 @@ func (archiveService *ArchiveService) stopProviders() {
 }
 
 // launchSpecificProvider Start a provider for a specific operation
-func (archiveService *ArchiveService) launchProvider(providerURL string) error {
+func (archiveService *ArchiveService) launchProvider(providerURL string, backend arch.ArchiveBackend) error {
 	// Inform the WaitGroup that this goroutine is finished at the end of this function
 	defer archiveService.wg.Done()
 	// Declare variables
@@ -288,7 +381,7 @@ func (archiveService *ArchiveService) launchProvider(providerURL string) error {
 	var err error
 
 	// Start Operation
-	provider, err = StartProvider(providerURL)
+	provider, err = StartProvider(providerURL, backend)
 	if err != nil {
 		return err
 	}
@@ -296,6 +389,17 @@ func (archiveService *ArchiveService) launchProvider(providerURL string) error {
 	// Close the provider at the end of the function
 	defer provider.Close()
 
+	// Split the big groups of objects of the queries
+	provider.SetMaxObjectsPerUpdate(archiveService.maxObjectsPerUpdate)
+
+	// Publish the events of the archive
+	if archiveService.brokerURL != "" {
+		err = provider.StartEventPublisher(NewURI(archiveService.brokerURL))
+		if err != nil {
+			return err
+		}
+	}
+
 	// Start communication
 	for archiveService.running == true {
 		time.Sleep(1 * time.Second)
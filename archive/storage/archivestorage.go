@@ -26,12 +26,9 @@
 package storage
 
 import (
-	"bytes"
 	"database/sql"
 	"errors"
-	"fmt"
-	"math/rand"
-	"reflect"
+	"strings"
 	"time"
 
 	. "github.com/ccsdsmo/malgo/com"
@@ -41,18 +38,77 @@ import (
 	. "github.com/etiennelndr/archiveservice/archive/constants"
 	"github.com/etiennelndr/archiveservice/archive/utils"
 	. "github.com/etiennelndr/archiveservice/data"
-
-	// Init mysql driver
-	_ "github.com/go-sql-driver/mysql"
 )
 
-// Database ids
-const (
-	USERNAME = "archiveService"
-	PASSWORD = "1a2B3c4D!@?"
-	DATABASE = "archive"
-	TABLE    = "Archive"
-)
+// SQLBackend is the ArchiveBackend which stores the objects of the
+// archive in a SQL database. Everything that differs from a database
+// to another is held by its dialect
+type SQLBackend struct {
+	dialect sqlDialect
+	table   string
+	// db is the pool of connections shared by all the operations
+	db *sql.DB
+	// idAllocator allocates the identifiers of the stored objects
+	idAllocator IDAllocator
+}
+
+// sqlDialect holds the specificities of a SQL database
+type sqlDialect interface {
+	// driverName returns the name of the database/sql driver
+	driverName() string
+	// dataSourceName returns the string used to open the database
+	dataSourceName() string
+	// rebind adapts a query written for MySQL (with '?' placeholders
+	// and backquoted columns) to the database
+	rebind(query string) string
+	// resetAutoIncrement sets the next id of the table to max(id)+1
+	resetAutoIncrement(tx *sql.Tx, table string) error
+	// migrations returns the migrations which create and upgrade the table
+	migrations(table string) []migration
+	// contains returns the condition checking if a column contains the
+	// string bound to its '?' placeholder, with or without the case
+	contains(column string, isCaseSensitive bool) string
+}
+
+// newSQLBackend creates a SQL backend, opens its pool of connections
+// and migrates the table of the archive to the last version
+func newSQLBackend(dialect sqlDialect, config Config) (*SQLBackend, error) {
+	table, err := config.table()
+	if err != nil {
+		return nil, err
+	}
+	idAllocator, err := NewIDAllocator(config.IDAllocator)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open the database
+	db, err := sql.Open(dialect.driverName(), dialect.dataSourceName())
+	if err != nil {
+		return nil, err
+	}
+	err = config.configurePool(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Validate the connection by pinging it
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	backend := &SQLBackend{dialect, table, db, idAllocator}
+	err = backend.migrate()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return backend, nil
+}
 
 // Database columns
 var databaseFields = []string{
@@ -75,578 +131,98 @@ var databaseFields = []string{
 //                            RETRIEVE                                  //
 //======================================================================//
 
-// RetrieveInArchive : TODO:
-func RetrieveInArchive(objectType ObjectType, identifierList IdentifierList, objectInstanceIdentifierList LongList) (ArchiveDetailsList, ElementList, error) {
-	// Create the transaction to execute future queries
-	db, tx, err := createTransaction()
+// RetrieveInArchive retrieves a set of objects identified by their object
+// instance identifiers. The objects are read when the cursor is moved, an
+// unknown object stops the cursor with a MAL_ERROR_UNKNOWN_MESSAGE error
+func (backend *SQLBackend) RetrieveInArchive(objectType ObjectType, identifierList IdentifierList, objectInstanceIdentifierList LongList) (ArchiveCursor, error) {
+	// Create the transaction used by the cursor
+	tx, err := backend.createTransaction()
 	if err != nil {
-		return nil, nil, err
-	}
-	defer db.Close()
-
-	// Convert domain
-	domain := utils.AdaptDomainToString(identifierList)
-
-	// First of all, we need to verity the object instance identifiers values
-	var isAll = false
-	for i := 0; i < objectInstanceIdentifierList.Size(); i++ {
-		if *objectInstanceIdentifierList[i] == 0 {
-			isAll = true
-			break
-		}
+		return nil, err
 	}
 
-	// Transform Type Short Form to List Short Form
-	listShortForm := utils.ConvertToListShortForm(objectType)
-	// Get Element in the MAL Registry
-	element, err := LookupMALElement(listShortForm)
-	if err != nil {
-		return nil, nil, err
+	var cursor = &sqlRetrieveCursor{
+		backend:                      backend,
+		tx:                           tx,
+		objectType:                   objectType,
+		domain:                       utils.AdaptDomainToString(identifierList),
+		objectInstanceIdentifierList: objectInstanceIdentifierList,
 	}
-
-	// select a.objectInstanceIdentifier, t, y, timestamp, `details.related`, network,
-	// provider, `details.source` from Archive a INNER JOIN Sine s ON
-	// a.objectInstanceIdentifier = s.objectInstanceIdentifier;
-
-	// Create variables to return the elements and information
-	var archiveDetailsList = *NewArchiveDetailsList(0)
-	var elementList = element.(ElementList)
-	elementList = elementList.CreateElement().(ElementList)
-	// Then, retrieve these elements and their information
-	if !isAll {
-		for i := 0; i < objectInstanceIdentifierList.Size(); i++ {
-			// Variables to store the different elements present in the database
-			var encodedObjectId []byte
-			var encodedElement []byte
-			var timestamp time.Time
-			var related Long
-			var network Identifier
-			var provider URI
-
-			// We can retrieve this object
-			err = tx.QueryRow("SELECT element, timestamp, `details.related`, network, provider, `details.source` FROM "+TABLE+" WHERE objectInstanceIdentifier = ? AND area = ? AND service = ? AND version = ? AND number = ? AND domain = ?",
-				*objectInstanceIdentifierList[i],
-				objectType.Area,
-				objectType.Service,
-				objectType.Version,
-				objectType.Number,
-				domain).Scan(&encodedElement,
-				&timestamp,
-				&related,
-				&network,
-				&provider,
-				&encodedObjectId)
-			if err != nil {
-				if err.Error() == "sql: no rows in result set" {
-					return nil, nil, errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
-				}
-				return nil, nil, err
-			}
-
-			// Decode the Element and the ObjectId for the ArchiveDetails
-			objectId, element, err := utils.DecodeElements(encodedObjectId, encodedElement)
-			if err != nil {
-				return nil, nil, err
-			}
-
-			// Create the ArchiveDetails
-			// First, create the ObjectDetails
-			objectDetails := ObjectDetails{&related, objectId}
-			// Create the ArchiveDetails
-			archiveDetails := &ArchiveDetails{
-				*objectInstanceIdentifierList[i],
-				objectDetails,
-				&network,
-				NewFineTime(timestamp),
-				&provider,
-			}
-
-			archiveDetailsList.AppendElement(archiveDetails)
-			elementList.AppendElement(element)
-		}
-	} else {
+	if isAllObjectInstanceIdentifiers(objectInstanceIdentifierList) {
 		// Retrieve all these elements (no particular object instance identifiers)
-		// Variables to store the different elements present in the database
-		var objectInstanceIdentifier Long
-		var encodedObjectId []byte
-		var encodedElement []byte
-		var timestamp time.Time
-		var related Long
-		var network Identifier
-		var provider URI
-
-		// Retrieve this object and its archive details in the archive
-		rows, err := tx.Query("SELECT objectInstanceIdentifier, element, timestamp, `details.related`, network, provider, `details.source` FROM "+TABLE+" WHERE area = ? AND service = ? AND version = ? AND number = ? AND domain = ?",
+		cursor.rows, err = tx.Query(backend.dialect.rebind("SELECT "+sqlObjectColumns+sqlElementColumn+" FROM "+backend.table+" WHERE area = ? AND service = ? AND version = ? AND number = ? AND domain = ?"),
 			objectType.Area,
 			objectType.Service,
 			objectType.Version,
 			objectType.Number,
-			domain)
+			cursor.domain)
 		if err != nil {
-			return nil, nil, err
-		}
-
-		var countElements int
-		for rows.Next() {
-			if err = rows.Scan(&objectInstanceIdentifier,
-				&encodedElement,
-				&timestamp,
-				&related,
-				&network,
-				&provider,
-				&encodedObjectId); err != nil {
-				return nil, nil, err
-			}
-
-			// Decode the Element and the ObjectId for the ArchiveDetails
-			objectId, element, err := utils.DecodeElements(encodedObjectId, encodedElement)
-			if err != nil {
-				return nil, nil, err
-			}
-
-			// Create the ArchiveDetails
-			// First, create the ObjectDetails
-			objectDetails := ObjectDetails{&related, objectId}
-			// Create the ArchiveDetails
-			archiveDetails := &ArchiveDetails{
-				objectInstanceIdentifier,
-				objectDetails,
-				&network,
-				NewFineTime(timestamp),
-				&provider,
-			}
-
-			archiveDetailsList.AppendElement(archiveDetails)
-			elementList.AppendElement(element)
-			countElements++
-		}
-
-		if countElements == 0 {
-			return nil, nil, errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
+			tx.Rollback()
+			return nil, err
 		}
 	}
 
-	// Commit changes
-	tx.Commit()
-
-	return archiveDetailsList, elementList, nil
+	return cursor, nil
 }
 
 //======================================================================//
 //                              QUERY                                   //
 //======================================================================//
 
-// QueryArchive : TODO:
-func QueryArchive(boolean *Boolean, objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) ([]*ObjectType, []*ArchiveDetailsList, []*IdentifierList, []ElementList, error) {
-	// Create the transaction to execute future queries
-	db, tx, err := createTransaction()
-	if err != nil {
-		return nil, nil, nil, nil, err
-	}
-	defer db.Close()
-
+// QueryArchive retrieves the objects matching an archive query. The rows
+// are sorted by group (object type and domain), so each group is read
+// from the database while the cursor is moved. The objects are grouped by
+// domain when their elements are requested or when the domain of the
+// archive query contains a wildcard, so each group has a concrete domain
+func (backend *SQLBackend) QueryArchive(boolean *Boolean, objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) (QueryCursor, error) {
 	// Verify the parameters
-	err = verifyParameters(archiveQuery, queryFilter)
+	err := verifyParameters(objectType, archiveQuery, queryFilter)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, err
 	}
 
-	var isObjectTypeEqualToZero = objectType.Area == 0 || objectType.Number == 0 || objectType.Service == 0 || objectType.Version == 0
+	var isObjectTypeEqualToZero = hasObjectTypeWildcard(objectType)
+	var isElementRequested = boolean != nil && *boolean == true
+	var isDomainWildcard = hasDomainWildcard(archiveQuery.Domain)
+	var filters = bodyFilters(queryFilter)
 
 	// First of all we have to create the query
-	query, err := createQuery(boolean, objectType, isObjectTypeEqualToZero, archiveQuery, queryFilter)
+	query, args, err := createQuery(backend.dialect, backend.table, isElementRequested || isDomainWildcard, isElementRequested || len(filters) > 0, objectType, isObjectTypeEqualToZero, archiveQuery, queryFilter)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, err
 	}
 
-	// Variables to return
-	var objectTypeToReturn []*ObjectType
-	var identifierListToReturn []*IdentifierList
-	var archiveDetailsListToReturn []*ArchiveDetailsList
-	var elementListToReturn []ElementList
-
-	if boolean != nil && *boolean == true && isObjectTypeEqualToZero == true {
-		// Retrieve all of the elements
-		// Variables to store the different elements present in the database
-		var objectInstanceIdentifier Long
-		var encodedObjectId []byte
-		var encodedElement []byte
-		var timestamp time.Time
-		var related Long
-		var network Identifier
-		var provider URI
-		var area UShort
-		var service UShort
-		var version UOctet
-		var number UShort
-		var domain string
-
-		rows, err := tx.Query(query)
-		if err != nil {
-			return nil, nil, nil, nil, err
-		}
-
-		// Define a slice to sort on the differents values to return
-		// TODO: find a name for this attribute
-		var findASmartName []interface{}
-		// Map for the different object types
-		var objectTypeMap = make(map[ObjectType]uint)
-		var countObjectType uint
-		// Map for the different domains
-		var domainMap = make(map[string]uint)
-		var countDomain uint
-
-		for rows.Next() {
-			if err = rows.Scan(&objectInstanceIdentifier, &timestamp, &related, &network, &provider, &encodedObjectId, &encodedElement, &domain, &area, &service, &version, &number); err != nil {
-				return nil, nil, nil, nil, err
-			}
-
-			//
-			var isAlreadyUsed = true
-			// Verify the object type value
-			objectTypeFromDB := ObjectType{area, service, version, number}
-			if _, ok := objectTypeMap[objectTypeFromDB]; !ok {
-				objectTypeMap[objectTypeFromDB] = countObjectType
-				countObjectType++
-				isAlreadyUsed = false
-			}
-			// Verify the domain value
-			if _, ok := domainMap[domain]; !ok {
-				domainMap[domain] = countDomain
-				countDomain++
-				isAlreadyUsed = false
-			}
-
-			if isAlreadyUsed {
-				var index uint
-				// Retrieve the index in the general slice
-				duo := []interface{}{objectTypeFromDB, domain}
-				for i := 0; i < len(findASmartName)/2; i++ {
-					if reflect.DeepEqual(duo, findASmartName[i]) {
-						index = uint(i)
-						break
-					}
-				}
-
-				// ArchiveDetailsList
-				// Decode the object id
-				objId, err := utils.DecodeObjectID(encodedObjectId)
-				if err != nil {
-					return nil, nil, nil, nil, err
-				}
-				objectDet := ObjectDetails{
-					&related,
-					objId,
-				}
-				archDetails := &ArchiveDetails{objectInstanceIdentifier, objectDet, &network, NewFineTime(timestamp), &provider}
-				// Append this ArchiveDetails to the desired ArchiveDetailsList
-				archiveDetailsListToReturn[index].AppendElement(archDetails)
-
-				// ElementList
-				// Decode the element
-				elem, err := utils.DecodeElement(encodedElement)
-				if err != nil {
-					return nil, nil, nil, nil, err
-				}
-				elementListToReturn[index].AppendElement(elem)
-			} else {
-				// First, append the objectType and the domain in the general slice
-				findASmartName = append(findASmartName, objectTypeFromDB, domain)
-
-				// IdentifierList
-				idList := utils.AdaptDomainToIdentifierList(domain)
-				identifierListToReturn = append(identifierListToReturn, &idList)
-
-				// ObjectType
-				objectTypeToReturn = append(objectTypeToReturn, &objectTypeFromDB)
-
-				// ArchiveDetailsList
-				archDetailsList := NewArchiveDetailsList(0)
-				// Decode the object id
-				objId, err := utils.DecodeObjectID(encodedObjectId)
-				if err != nil {
-					return nil, nil, nil, nil, err
-				}
-				objectDet := ObjectDetails{
-					&related,
-					objId,
-				}
-				archDetails := &ArchiveDetails{objectInstanceIdentifier, objectDet, &network, NewFineTime(timestamp), &provider}
-				archDetailsList.AppendElement(archDetails)
-				archiveDetailsListToReturn = append(archiveDetailsListToReturn, archDetailsList)
-
-				// ElementList
-				// Decode the element
-				elem, err := utils.DecodeElement(encodedElement)
-				if err != nil {
-					return nil, nil, nil, nil, err
-				}
-
-				// Transform Type Short Form to List Short Form
-				listShortForm := utils.ConvertToListShortForm(objectTypeFromDB)
-				// Get Element in the MAL Registry
-				element, err := LookupMALElement(listShortForm)
-				var elementList = element.(ElementList)
-				elementList = elementList.CreateElement().(ElementList)
-
-				elementList.AppendElement(elem)
-				elementListToReturn = append(elementListToReturn, elementList)
-			}
-		}
-	} else if boolean != nil && *boolean == true && isObjectTypeEqualToZero == false {
-		// Retrieve all of the elements unless the object type
-		// Variables to store the different elements present in the database
-		var objectInstanceIdentifier Long
-		var encodedObjectId []byte
-		var encodedElement []byte
-		var timestamp time.Time
-		var related Long
-		var network Identifier
-		var provider URI
-		var domain string
-		var area UShort
-		var service UShort
-		var version UOctet
-		var number UShort
-
-		rows, err := tx.Query(query)
-		if err != nil {
-			return nil, nil, nil, nil, err
-		}
-
-		// Map for the different domains
-		var domainMap = make(map[string]uint)
-		var countDomain uint
-
-		for rows.Next() {
-			if err = rows.Scan(&objectInstanceIdentifier, &timestamp, &related, &network, &provider, &encodedObjectId, &encodedElement, &domain, &area, &service, &version, &number); err != nil {
-				return nil, nil, nil, nil, err
-			}
-
-			//
-			var isAlreadyUsed = true
-			// Verify the domain value
-			if _, ok := domainMap[domain]; !ok {
-				domainMap[domain] = countDomain
-				countDomain++
-				isAlreadyUsed = false
-			}
-
-			if isAlreadyUsed {
-				// ArchiveDetailsList
-				// Decode the object id
-				objID, err := utils.DecodeObjectID(encodedObjectId)
-				if err != nil {
-					return nil, nil, nil, nil, err
-				}
-				objectDet := ObjectDetails{
-					Related: &related,
-					Source:  objID,
-				}
-				archDetails := &ArchiveDetails{objectInstanceIdentifier, objectDet, &network, NewFineTime(timestamp), &provider}
-				// Append this ArchiveDetails to the desired ArchiveDetailsList
-				archiveDetailsListToReturn[domainMap[domain]].AppendElement(archDetails)
-
-				// ElementList
-				// Decode the element
-				elem, err := utils.DecodeElement(encodedElement)
-				if err != nil {
-					return nil, nil, nil, nil, err
-				}
-				elementListToReturn[domainMap[domain]].AppendElement(elem)
-			} else {
-				// IdentifierList
-				idList := utils.AdaptDomainToIdentifierList(domain)
-				identifierListToReturn = append(identifierListToReturn, &idList)
-
-				// ObjectType
-				objectTypeToReturn = append(objectTypeToReturn, nil)
-
-				// ArchiveDetailsList
-				archDetailsList := NewArchiveDetailsList(0)
-				// Decode the object id
-				objID, err := utils.DecodeObjectID(encodedObjectId)
-				if err != nil {
-					return nil, nil, nil, nil, err
-				}
-				objectDet := ObjectDetails{
-					Related: &related,
-					Source:  objID,
-				}
-				archDetails := &ArchiveDetails{objectInstanceIdentifier, objectDet, &network, NewFineTime(timestamp), &provider}
-				archDetailsList.AppendElement(archDetails)
-				archiveDetailsListToReturn = append(archiveDetailsListToReturn, archDetailsList)
-
-				// ElementList
-				// Decode the element
-				elem, err := utils.DecodeElement(encodedElement)
-				if err != nil {
-					return nil, nil, nil, nil, err
-				}
-				// Transform Type Short Form to List Short Form
-				listShortForm := utils.ConvertToListShortForm(ObjectType{Area: area, Service: service, Version: version, Number: number})
-				// Get Element in the MAL Registry
-				element, err := LookupMALElement(listShortForm)
-				var elementList = element.(ElementList)
-				elementList = elementList.CreateElement().(ElementList)
-
-				elementList.AppendElement(elem)
-				elementListToReturn = append(elementListToReturn, elementList)
-			}
-		}
-	} else if (boolean == nil || *boolean == false) && isObjectTypeEqualToZero == true {
-		// Retrieve only the object type and the archive details
-		// Variables to store the different elements present in the database
-		var objectInstanceIdentifier Long
-		var encodedObjectId []byte
-		var timestamp time.Time
-		var related Long
-		var network Identifier
-		var provider URI
-		var area UShort
-		var service UShort
-		var version UOctet
-		var number UShort
-
-		rows, err := tx.Query(query)
-		if err != nil {
-			return nil, nil, nil, nil, err
-		}
-
-		// Map for the different object types
-		var objectTypeMap = make(map[ObjectType]uint)
-		var countObjectType uint
-
-		for rows.Next() {
-			if err = rows.Scan(&objectInstanceIdentifier, &timestamp, &related, &network, &provider, &encodedObjectId, &area, &service, &version, &number); err != nil {
-				return nil, nil, nil, nil, err
-			}
-
-			//
-			var isAlreadyUsed = true
-			// Verify the object type value
-			objectTypeFromDB := ObjectType{area, service, version, number}
-			if _, ok := objectTypeMap[objectTypeFromDB]; !ok {
-				objectTypeMap[objectTypeFromDB] = countObjectType
-				countObjectType++
-				isAlreadyUsed = false
-			}
-
-			if isAlreadyUsed {
-				// ArchiveDetailsList
-				// Decode the object id
-				objId, err := utils.DecodeObjectID(encodedObjectId)
-				if err != nil {
-					return nil, nil, nil, nil, err
-				}
-				objectDet := ObjectDetails{
-					&related,
-					objId,
-				}
-				archDetails := &ArchiveDetails{objectInstanceIdentifier, objectDet, &network, NewFineTime(timestamp), &provider}
-				// Append this ArchiveDetails to the desired ArchiveDetailsList
-				archiveDetailsListToReturn[objectTypeMap[objectTypeFromDB]].AppendElement(archDetails)
-
-			} else {
-				// IdentifierList
-				identifierListToReturn = append(identifierListToReturn, nil)
-
-				// ObjectType
-				objectTypeToReturn = append(objectTypeToReturn, &objectTypeFromDB)
-
-				// ArchiveDetailsList
-				archDetailsList := NewArchiveDetailsList(0)
-				// Decode the object id
-				objId, err := utils.DecodeObjectID(encodedObjectId)
-				if err != nil {
-					return nil, nil, nil, nil, err
-				}
-				objectDet := ObjectDetails{
-					&related,
-					objId,
-				}
-				archDetails := &ArchiveDetails{objectInstanceIdentifier, objectDet, &network, NewFineTime(timestamp), &provider}
-				archDetailsList.AppendElement(archDetails)
-				archiveDetailsListToReturn = append(archiveDetailsListToReturn, archDetailsList)
-
-				// ElementList
-				var longList *LongList
-				elementListToReturn = append(elementListToReturn, longList)
-			}
-		}
-	} else { // (*boolean == false or boolean == nil) and isObjectTypeEqualToZero == false
-		// Retrieve only the archive details
-		// Variables to store the different elements present in the database
-		var objectInstanceIdentifier Long
-		var encodedObjectId []byte
-		var timestamp time.Time
-		var related Long
-		var network Identifier
-		var provider URI
-
-		rows, err := tx.Query(query)
-		if err != nil {
-			return nil, nil, nil, nil, err
-		}
-
-		// Set the identifierListToReturn to null
-		identifierListToReturn = append(identifierListToReturn, nil)
-		// Set the objectTypeToReturn to null
-		objectTypeToReturn = append(objectTypeToReturn, nil)
-		// Set the ElementList to null
-		var longList *LongList
-		elementListToReturn = append(elementListToReturn, longList)
-
-		var isAlreadyUsed = false
-		for rows.Next() {
-			if err = rows.Scan(&objectInstanceIdentifier, &timestamp, &related, &network, &provider, &encodedObjectId); err != nil {
-				return nil, nil, nil, nil, err
-			}
-
-			// ArchiveDetailsList
-			// Decode the object id
-			objId, err := utils.DecodeObjectID(encodedObjectId)
-			if err != nil {
-				return nil, nil, nil, nil, err
-			}
-			objectDet := ObjectDetails{
-				&related,
-				objId,
-			}
-			archDetails := &ArchiveDetails{objectInstanceIdentifier, objectDet, &network, NewFineTime(timestamp), &provider}
-			// Append this ArchiveDetails to the desired ArchiveDetailsList
-			if !isAlreadyUsed {
-				// ArchiveDetailsList
-				archDetailsList := NewArchiveDetailsList(0)
-				archDetailsList.AppendElement(archDetails)
-				archiveDetailsListToReturn = append(archiveDetailsListToReturn, archDetailsList)
-				isAlreadyUsed = true
-			} else {
-				archiveDetailsListToReturn[0].AppendElement(archDetails)
-			}
-		}
+	// Create the transaction used by the cursor
+	tx, err := backend.createTransaction()
+	if err != nil {
+		return nil, err
 	}
-
-	// Finally, it is useful to verify the size of the archive details
-	// list (if it is equal to 0 we have to append a nil element to
-	// each list)
-	if len(archiveDetailsListToReturn) == 0 {
-		var longList *LongList
-		objectTypeToReturn = append(objectTypeToReturn, nil)
-		archiveDetailsListToReturn = append(archiveDetailsListToReturn, nil)
-		identifierListToReturn = append(identifierListToReturn, nil)
-		elementListToReturn = append(elementListToReturn, longList)
-		// TODO: maybe find another way to initialize elementListToReturn
+	rows, err := tx.Query(backend.dialect.rebind(query), args...)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
-	// Commit changes
-	tx.Commit()
-
-	return objectTypeToReturn, archiveDetailsListToReturn, identifierListToReturn, elementListToReturn, nil
+	var cursor = &sqlQueryCursor{
+		tx:                 tx,
+		rows:               rows,
+		isElementRequested: isElementRequested,
+		isDomainGrouped:    isElementRequested || isDomainWildcard,
+		bodyFilters:        filters,
+	}
+	if isDomainWildcard {
+		cursor.queryDomain = archiveQuery.Domain
+	}
+	return cursor, nil
 }
 
 // verifyParameters : TODO:
-func verifyParameters(archiveQuery ArchiveQuery, queryFilter QueryFilter) error {
+func verifyParameters(objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) error {
+	// Check the identifiers of the domain
+	if archiveQuery.Domain != nil && utils.HasNullIdentifier(*archiveQuery.Domain) {
+		return errors.New(string(ARCHIVE_SERVICE_QUERY_DOMAIN_NULL_IDENTIFIER_ERROR))
+	}
+
 	// Check sortFieldName value
 	var isSortFieldNameADefinedField = false
 	for i := 0; i < len(databaseFields); i++ {
@@ -667,12 +243,18 @@ func verifyParameters(archiveQuery ArchiveQuery, queryFilter QueryFilter) error
 
 		for i := 0; i < compositerFilterSet.Filters.Size(); i++ {
 			var filter = compositerFilterSet.Filters.GetElementAt(i).(*CompositeFilter)
-			if (filter.Type == COM_EXPRESSIONOPERATOR_CONTAINS || filter.Type == COM_EXPRESSIONOPERATOR_ICONTAINS || filter.Type == COM_EXPRESSIONOPERATOR_GREATER || filter.Type == COM_EXPRESSIONOPERATOR_GREATER_OR_EQUAL || filter.Type == COM_EXPRESSIONOPERATOR_LESS || filter.Type == COM_EXPRESSIONOPERATOR_LESS_OR_EQUAL) && filter.FieldValue == nil {
+			if filter.Type < COM_EXPRESSIONOPERATOR_EQUAL || filter.Type > COM_EXPRESSIONOPERATOR_ICONTAINS {
+				return errors.New(string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR) + ": unknown expression operator")
+			} else if (filter.Type == COM_EXPRESSIONOPERATOR_CONTAINS || filter.Type == COM_EXPRESSIONOPERATOR_ICONTAINS || filter.Type == COM_EXPRESSIONOPERATOR_GREATER || filter.Type == COM_EXPRESSIONOPERATOR_GREATER_OR_EQUAL || filter.Type == COM_EXPRESSIONOPERATOR_LESS || filter.Type == COM_EXPRESSIONOPERATOR_LESS_OR_EQUAL) && filter.FieldValue == nil {
 				return errors.New(string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR) + ": must not contain NULL value")
 			} else if _, ok := filter.FieldValue.(*Blob); ok {
 				if filter.Type != COM_EXPRESSIONOPERATOR_EQUAL && filter.Type != COM_EXPRESSIONOPERATOR_DIFFER {
 					return errors.New(string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR) + ": must not use this expression operator for a blob")
 				}
+			} else if _, ok := filter.FieldValue.(*Boolean); ok {
+				if filter.Type != COM_EXPRESSIONOPERATOR_EQUAL && filter.Type != COM_EXPRESSIONOPERATOR_DIFFER {
+					return errors.New(string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR) + ": must not use this expression operator for a boolean")
+				}
 			} else if filter.Type == COM_EXPRESSIONOPERATOR_CONTAINS || filter.Type == COM_EXPRESSIONOPERATOR_ICONTAINS {
 				if _, ok := filter.FieldValue.(*String); !ok {
 					return errors.New(string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR) + ": must not use this expression operator for a non-String")
@@ -681,7 +263,8 @@ func verifyParameters(archiveQuery ArchiveQuery, queryFilter QueryFilter) error
 		}
 	}
 
-	return nil
+	// Check the fields of the filters on the body of the objects
+	return verifyBodyFilters(objectType, queryFilter)
 }
 
 //======================================================================//
@@ -689,13 +272,13 @@ func verifyParameters(archiveQuery ArchiveQuery, queryFilter QueryFilter) error
 //======================================================================//
 
 // CountInArchive : TODO:
-func CountInArchive(objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (*LongList, error) {
+func (backend *SQLBackend) CountInArchive(objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (*LongList, error) {
 	// Create the transaction to execute future queries
-	db, tx, err := createTransaction()
+	tx, err := backend.createTransaction()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
+	defer tx.Rollback()
 
 	//
 	var longList = NewLongList(0)
@@ -703,28 +286,38 @@ func CountInArchive(objectType ObjectType, archiveQueryList ArchiveQueryList, qu
 	for i := 0; i < archiveQueryList.Size(); i++ {
 		// Verify the parameters
 		if queryFilterList != nil {
-			err = verifyParameters(*archiveQueryList[i], queryFilterList.GetElementAt(i))
+			err = verifyParameters(objectType, *archiveQueryList[i], queryFilterList.GetElementAt(i))
 		} else {
-			err = verifyParameters(*archiveQueryList[i], nil)
+			err = verifyParameters(objectType, *archiveQueryList[i], nil)
 		}
 		if err != nil {
 			return nil, err
 		}
-		// Create the query
-		var query string
+		var queryFilter QueryFilter
 		if queryFilterList != nil {
-			query, err = createCountQuery(objectType, *archiveQueryList[i], queryFilterList.GetElementAt(i))
-		} else {
-			query, err = createCountQuery(objectType, *archiveQueryList[i], nil)
+			queryFilter = queryFilterList.GetElementAt(i)
+		}
+		var filters = bodyFilters(queryFilter)
+		var queryDomain *IdentifierList
+		if hasDomainWildcard(archiveQueryList[i].Domain) {
+			queryDomain = archiveQueryList[i].Domain
 		}
+		// Create the query
+		query, args, err := createCountQuery(backend.dialect, backend.table, objectType, *archiveQueryList[i], queryFilter, queryDomain != nil, len(filters) > 0)
 		if err != nil {
 			return nil, err
 		}
 
 		// Create a variable to Store the response
 		var response int64
-		// Execute the query
-		err = tx.QueryRow(query).Scan(&response)
+		if queryDomain == nil && len(filters) == 0 {
+			// Execute the query
+			err = tx.QueryRow(backend.dialect.rebind(query), args...).Scan(&response)
+		} else {
+			// The wildcard domain and the filters on the body
+			// of the objects are evaluated here
+			response, err = countMatchingObjects(tx, backend.dialect.rebind(query), args, queryDomain, filters)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -744,21 +337,21 @@ func CountInArchive(objectType ObjectType, archiveQueryList ArchiveQueryList, qu
 //======================================================================//
 
 // StoreInArchive : Use this function to store objects in an COM archive
-func StoreInArchive(boolean *Boolean, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*LongList, error) {
-	rand.Seed(time.Now().UnixNano())
-
+func (backend *SQLBackend) StoreInArchive(boolean *Boolean, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*LongList, error) {
 	// Create the transaction to execute future queries
-	db, tx, err := createTransaction()
+	tx, err := backend.createTransaction()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
+	defer tx.Rollback()
 
 	// Variable to return all the object instance identifiers
 	var longList *LongList
 
 	// Create the domain (It might change in the future)
 	domain := utils.AdaptDomainToString(identifierList)
+	// The object instance identifiers are unique per object type and domain
+	var scope = IDScope{objectType, domain}
 
 	// Init the list to return (if boolean is not equal to false)
 	if boolean != nil && *boolean {
@@ -767,34 +360,34 @@ func StoreInArchive(boolean *Boolean, objectType ObjectType, identifierList Iden
 	for i := 0; i < archiveDetailsList.Size(); i++ {
 		if archiveDetailsList[i].InstId == 0 {
 			// We have to create a new and unused object instance identifier
-			for {
-				var objectInstanceIdentifier = rand.Int63n(int64(LONG_MAX))
-				isObjInstIDInDB, err := isObjectInstanceIdentifierInDatabase(tx, objectInstanceIdentifier)
-				if err != nil {
-					// An error occurred, do a rollback
-					tx.Rollback()
-					return nil, err
-				}
-				if !isObjInstIDInDB {
-					// OK, we can insert the object with this instance identifier
-					err := insertInDatabase(tx, objectInstanceIdentifier, elementList.GetElementAt(i), objectType, domain, *archiveDetailsList[i])
-					if err != nil {
-						// An error occurred, do a rollback
-						tx.Rollback()
-						return nil, err
-					}
-
-					if boolean != nil && *boolean {
-						// Insert this new object instance identifier in the returned list
-						longList.AppendElement(NewLong(objectInstanceIdentifier))
-					}
-
-					break
-				}
+			objectInstanceIdentifier, err := allocateID(backend.idAllocator, scope,
+				func() (Long, error) {
+					return backend.lastObjectInstanceIdentifier(tx, scope)
+				},
+				func(objectInstanceIdentifier Long) (bool, error) {
+					return backend.isObjectInstanceIdentifierInDatabase(tx, scope, objectInstanceIdentifier)
+				})
+			if err != nil {
+				// An error occurred, do a rollback
+				tx.Rollback()
+				return nil, err
+			}
+
+			// OK, we can insert the object with this instance identifier
+			err = backend.insertInDatabase(tx, int64(objectInstanceIdentifier), elementList.GetElementAt(i), objectType, domain, *archiveDetailsList[i])
+			if err != nil {
+				// An error occurred, do a rollback
+				tx.Rollback()
+				return nil, err
+			}
+
+			if boolean != nil && *boolean {
+				// Insert this new object instance identifier in the returned list
+				longList.AppendElement(NewLong(int64(objectInstanceIdentifier)))
 			}
 		} else {
 			// We must verify if the object instance identifier is not already present in the table
-			isObjInstIDInDB, err := isObjectInstanceIdentifierInDatabase(tx, int64(archiveDetailsList[i].InstId))
+			isObjInstIDInDB, err := backend.isObjectInstanceIdentifierInDatabase(tx, scope, archiveDetailsList[i].InstId)
 			if err != nil {
 				// An error occurred, do a rollback
 				tx.Rollback()
@@ -807,7 +400,7 @@ func StoreInArchive(boolean *Boolean, objectType ObjectType, identifierList Iden
 			}
 
 			// This object is not present in the archive
-			err = insertInDatabase(tx, int64(archiveDetailsList[i].InstId), elementList.GetElementAt(i), objectType, domain, *archiveDetailsList[i])
+			err = backend.insertInDatabase(tx, int64(archiveDetailsList[i].InstId), elementList.GetElementAt(i), objectType, domain, *archiveDetailsList[i])
 			if err != nil {
 				// An error occurred, do a rollback
 				tx.Rollback()
@@ -832,13 +425,13 @@ func StoreInArchive(boolean *Boolean, objectType ObjectType, identifierList Iden
 //======================================================================//
 
 // UpdateArchive : TODO:
-func UpdateArchive(objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) error {
+func (backend *SQLBackend) UpdateArchive(objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) error {
 	// Create the transaction to execute future queries
-	db, tx, err := createTransaction()
+	tx, err := backend.createTransaction()
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer tx.Rollback()
 
 	// Create the domain (It might change in the future)
 	domain := utils.AdaptDomainToString(identifierList)
@@ -847,7 +440,7 @@ func UpdateArchive(objectType ObjectType, identifierList IdentifierList, archive
 		// First of all, we need to verify if the object instance identifier, combined
 		// with the object type and the domain which are in the archive
 		var queryReturn int
-		err := tx.QueryRow("SELECT objectInstanceIdentifier FROM "+TABLE+" WHERE objectInstanceIdentifier = ? AND area = ? AND service = ? AND version = ? AND number = ? AND domain = ?",
+		err := tx.QueryRow(backend.dialect.rebind("SELECT objectInstanceIdentifier FROM "+backend.table+" WHERE objectInstanceIdentifier = ? AND area = ? AND service = ? AND version = ? AND number = ? AND domain = ?"),
 			archiveDetailsList[i].InstId,
 			objectType.Area,
 			objectType.Service,
@@ -868,7 +461,7 @@ func UpdateArchive(objectType ObjectType, identifierList IdentifierList, archive
 			return err
 		}
 		// If no error, the object is in the archive and we can update it
-		_, err = tx.Exec("UPDATE "+TABLE+" SET element = ?, timestamp = ?, `details.related` = ?, network = ?, provider = ?, `details.source` = ? WHERE objectInstanceIdentifier = ? AND area = ? AND service = ? AND version = ? AND number = ? AND domain = ?",
+		_, err = tx.Exec(backend.dialect.rebind("UPDATE "+backend.table+" SET element = ?, timestamp = ?, `details.related` = ?, network = ?, provider = ?, `details.source` = ? WHERE objectInstanceIdentifier = ? AND area = ? AND service = ? AND version = ? AND number = ? AND domain = ?"),
 			encodedElement,
 			time.Time(*archiveDetailsList[i].Timestamp),
 			*archiveDetailsList[i].Details.Related,
@@ -897,17 +490,20 @@ func UpdateArchive(objectType ObjectType, identifierList IdentifierList, archive
 //                              DELETE                                  //
 //======================================================================//
 
-// DeleteInArchive : TODO:
-func DeleteInArchive(objectType ObjectType, identifierList IdentifierList, longListRequest LongList) (LongList, error) {
+// DeleteInArchive : deletes objects from the archive. The objects are read
+// in the transaction of the delete, so the returned cursor holds them as
+// they were when they have been deleted
+func (backend *SQLBackend) DeleteInArchive(objectType ObjectType, identifierList IdentifierList, longListRequest LongList) (LongList, ArchiveCursor, error) {
 	// Create the transaction to execute future queries
-	db, tx, err := createTransaction()
+	tx, err := backend.createTransaction()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer db.Close()
+	defer tx.Rollback()
 
-	// Variable to return
+	// Variables to return
 	var longList LongList
+	var cursor = new(sqlDeletedCursor)
 
 	// Create the domain (It might change in the future)
 	domain := utils.AdaptDomainToString(identifierList)
@@ -922,34 +518,41 @@ func DeleteInArchive(objectType ObjectType, identifierList IdentifierList, longL
 	}
 
 	if isAll {
-		// Retrieve the objectInstanceIdentifier
-		rows, err := tx.Query("SELECT objectInstanceIdentifier FROM "+TABLE+" WHERE area = ? AND service = ? AND version = ? AND number = ? AND domain = ?",
+		// Retrieve the objects
+		rows, err := tx.Query(backend.dialect.rebind("SELECT "+sqlObjectColumns+sqlElementColumn+" FROM "+backend.table+" WHERE area = ? AND service = ? AND version = ? AND number = ? AND domain = ?"),
 			objectType.Area,
 			objectType.Service,
 			objectType.Version,
 			objectType.Number,
 			domain)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		defer rows.Close()
 
 		var countElements int
 		for rows.Next() {
-			var instID Long
-			if err = rows.Scan(&instID); err != nil {
-				return nil, err
+			var object sqlObject
+			if err = object.scan(rows, false, true); err != nil {
+				return nil, nil, err
 			}
 
+			var instID = object.objectInstanceIdentifier
 			longList.AppendElement(&instID)
+			cursor.objects = append(cursor.objects, object)
 			countElements++
 		}
+		if err = rows.Err(); err != nil {
+			return nil, nil, err
+		}
+		rows.Close()
 
 		if countElements == 0 {
-			return nil, errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
+			return nil, nil, errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
 		}
 
 		// Delete all these objects
-		_, err = tx.Exec("DELETE FROM "+TABLE+" WHERE area = ? AND service = ? AND version = ? AND number = ? AND domain = ?",
+		_, err = tx.Exec(backend.dialect.rebind("DELETE FROM "+backend.table+" WHERE area = ? AND service = ? AND version = ? AND number = ? AND domain = ?"),
 			objectType.Area,
 			objectType.Service,
 			objectType.Version,
@@ -957,35 +560,36 @@ func DeleteInArchive(objectType ObjectType, identifierList IdentifierList, longL
 			domain)
 		if err != nil {
 			tx.Rollback()
-			return nil, err
+			return nil, nil, err
 		}
 
 		// Set AUTO_INCREMENT to max(id)+1
-		err = resetAutoIncrement(tx)
+		err = backend.dialect.resetAutoIncrement(tx, backend.table)
 		if err != nil {
 			tx.Rollback()
-			return nil, err
+			return nil, nil, err
 		}
 	} else {
 		for i := 0; i < longListRequest.Size(); i++ {
-			// Check if the object is in the archive
-			var objInstID int
-			err := tx.QueryRow("SELECT objectInstanceIdentifier FROM "+TABLE+" WHERE objectInstanceIdentifier = ? AND area = ? AND service = ? AND version = ? AND number = ? AND domain = ?",
+			// Check if the object is in the archive and read it
+			var object sqlObject
+			row := tx.QueryRow(backend.dialect.rebind("SELECT "+sqlObjectColumns+sqlElementColumn+" FROM "+backend.table+" WHERE objectInstanceIdentifier = ? AND area = ? AND service = ? AND version = ? AND number = ? AND domain = ?"),
 				*longListRequest[i],
 				objectType.Area,
 				objectType.Service,
 				objectType.Version,
 				objectType.Number,
-				domain).Scan(&objInstID)
+				domain)
+			err := object.scan(row, false, true)
 			if err != nil {
 				tx.Rollback()
 				if err.Error() == "sql: no rows in result set" {
-					return nil, errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
+					return nil, nil, errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
 				}
-				return nil, err
+				return nil, nil, err
 			}
 
-			_, err = tx.Exec("DELETE FROM "+TABLE+" WHERE objectInstanceIdentifier = ? AND area = ? AND service = ? AND version = ? AND number = ? AND domain = ?",
+			_, err = tx.Exec(backend.dialect.rebind("DELETE FROM "+backend.table+" WHERE objectInstanceIdentifier = ? AND area = ? AND service = ? AND version = ? AND number = ? AND domain = ?"),
 				*longListRequest[i],
 				objectType.Area,
 				objectType.Service,
@@ -994,60 +598,60 @@ func DeleteInArchive(objectType ObjectType, identifierList IdentifierList, longL
 				domain)
 			if err != nil {
 				tx.Rollback()
-				return nil, err
+				return nil, nil, err
 			}
 
 			longList.AppendElement(longListRequest.GetElementAt(i))
+			cursor.objects = append(cursor.objects, object)
 		}
 
 		// Set AUTO_INCREMENT to max(id)+1
-		err = resetAutoIncrement(tx)
+		err = backend.dialect.resetAutoIncrement(tx, backend.table)
 		if err != nil {
 			tx.Rollback()
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	// Commit changes
-	tx.Commit()
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return longList, nil
+	return longList, cursor, nil
 }
 
 //======================================================================//
 //                           LOCAL FUNCTIONS                            //
 //======================================================================//
-// createTransaction : TODO:
-func createTransaction() (*sql.DB, *sql.Tx, error) {
-	// Open the database
-	db, err := sql.Open("mysql", USERNAME+":"+PASSWORD+"@/"+DATABASE+"?parseTime=true")
-	if err != nil {
-		return nil, nil, err
-	}
-
-	// Validate the connection by pinging it
-	err = db.Ping()
-	if err != nil {
-		return nil, nil, err
-	}
-
-	// Create the transaction (we have to use this method to use rollback and commit)
-	tx, err := db.Begin()
-	if err != nil {
-		return nil, nil, err
-	}
+// createTransaction borrows a connection from the pool of the backend
+// to begin a transaction. The caller has to defer tx.Rollback(), which
+// does nothing once the transaction has been committed, to give the
+// connection back to the pool whatever happens
+func (backend *SQLBackend) createTransaction() (*sql.Tx, error) {
+	return backend.db.Begin()
+}
 
-	return db, tx, nil
+// Close closes the pool of connections to the database
+func (backend *SQLBackend) Close() error {
+	return backend.db.Close()
 }
 
 // isObjectInstanceIdentifierInDatabase: This function allows to verify if an instance of
-// an object is already in the archive
-func isObjectInstanceIdentifierInDatabase(tx *sql.Tx, objectInstanceIdentifier int64) (bool, error) {
+// an object is already in the archive, with the same object type and domain
+func (backend *SQLBackend) isObjectInstanceIdentifierInDatabase(tx *sql.Tx, scope IDScope, objectInstanceIdentifier Long) (bool, error) {
 	// Execute the query
 	// Before, create a variable to retrieve the result
-	var queryReturn int
+	var queryReturn int64
 	// Then, execute the query
-	err := tx.QueryRow("SELECT objectInstanceIdentifier FROM "+TABLE+" WHERE objectInstanceIdentifier = ? ", objectInstanceIdentifier).Scan(&queryReturn)
+	err := tx.QueryRow(backend.dialect.rebind("SELECT objectInstanceIdentifier FROM "+backend.table+" WHERE objectInstanceIdentifier = ? AND area = ? AND service = ? AND version = ? AND number = ? AND domain = ?"),
+		int64(objectInstanceIdentifier),
+		scope.ObjectType.Area,
+		scope.ObjectType.Service,
+		scope.ObjectType.Version,
+		scope.ObjectType.Number,
+		scope.Domain).Scan(&queryReturn)
 	if err != nil {
 		if err.Error() != "sql: no rows in result set" {
 			return false, err
@@ -1057,8 +661,30 @@ func isObjectInstanceIdentifierInDatabase(tx *sql.Tx, objectInstanceIdentifier i
 	return true, nil
 }
 
+// lastObjectInstanceIdentifier returns the highest object instance identifier
+// used by the objects of an object type and a domain, 0 if there isn't any
+func (backend *SQLBackend) lastObjectInstanceIdentifier(tx *sql.Tx, scope IDScope) (Long, error) {
+	var lastID sql.NullInt64
+	err := tx.QueryRow(backend.dialect.rebind("SELECT MAX(objectInstanceIdentifier) FROM "+backend.table+" WHERE area = ? AND service = ? AND version = ? AND number = ? AND domain = ?"),
+		scope.ObjectType.Area,
+		scope.ObjectType.Service,
+		scope.ObjectType.Version,
+		scope.ObjectType.Number,
+		scope.Domain).Scan(&lastID)
+	if err != nil {
+		return 0, err
+	}
+	return Long(lastID.Int64), nil
+}
+
+// SetIDAllocator replaces the allocator of the object instance identifiers
+// (see Config.IDAllocator). It must be called before the backend is used
+func (backend *SQLBackend) SetIDAllocator(allocator IDAllocator) {
+	backend.idAllocator = allocator
+}
+
 // insertInDatabase: This function allows to insert an element in the archive
-func insertInDatabase(tx *sql.Tx, objectInstanceIdentifier int64, element Element, objectType ObjectType, domain String, archiveDetails ArchiveDetails) error {
+func (backend *SQLBackend) insertInDatabase(tx *sql.Tx, objectInstanceIdentifier int64, element Element, objectType ObjectType, domain String, archiveDetails ArchiveDetails) error {
 	// Encode the Element and the ObjectId from the ArchiveDetails
 	encodedElement, encodedObjectID, err := utils.EncodeElements(element, *archiveDetails.Details.Source)
 	if err != nil {
@@ -1066,7 +692,8 @@ func insertInDatabase(tx *sql.Tx, objectInstanceIdentifier int64, element Elemen
 	}
 
 	// Execute the query to insert all the values in the database
-	_, err = tx.Exec("INSERT INTO "+TABLE+" VALUES ( NULL , ? , ? , ? , ? , ? , ? , ? , ? , ? , ? , ? , ? )",
+	// (the id is generated by the database)
+	_, err = tx.Exec(backend.dialect.rebind("INSERT INTO "+backend.table+" (objectInstanceIdentifier, element, area, service, version, number, domain, timestamp, `details.related`, network, provider, `details.source`) VALUES ( ? , ? , ? , ? , ? , ? , ? , ? , ? , ? , ? , ? )"),
 		objectInstanceIdentifier,
 		encodedElement,
 		objectType.Area,
@@ -1085,132 +712,135 @@ func insertInDatabase(tx *sql.Tx, objectInstanceIdentifier int64, element Elemen
 	return nil
 }
 
-// resetAutoIncrement takes the maximum id in the database and set the
-// AUTO_INCREMENT at this value (actually it's this value to which we added 1)
-func resetAutoIncrement(tx *sql.Tx) error {
-	// Create a value to store the maximum id (to which we added 1)
-	_, err := tx.Exec("SELECT @max := max(id)+1 FROM " + TABLE)
-	if err != nil {
-		return err
-	}
-	// Create the statement (in our case, we use an "alter table" statement)
-	_, err = tx.Exec("SET @alter_statement = CONCAT('ALTER TABLE " + TABLE + " AUTO_INCREMENT = ', @max)")
-	if err != nil {
-		return err
+// createCountQuery allows the provider to create automatically a query for the Count
+// operation. The domains and the elements are selected instead of being counted when
+// the domain contains a wildcard or when the query filter applies to the body of the
+// objects (see countMatchingObjects)
+func createCountQuery(dialect sqlDialect, table string, objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter, isDomainSelected bool, isElementSelected bool) (string, []interface{}, error) {
+	var builder = queryBuilder{dialect: dialect}
+	// Only CompositeFilterSet type should be used
+	var columns []string
+	if isDomainSelected {
+		columns = append(columns, "domain")
 	}
-	// Prepare the statement
-	_, err = tx.Exec("PREPARE stmt1 FROM @alter_statement;")
-	if err != nil {
-		return err
+	if isElementSelected {
+		columns = append(columns, "element")
 	}
-	// Then execute the statement
-	_, err = tx.Exec("EXECUTE stmt1")
-	if err != nil {
-		return err
+	if len(columns) > 0 {
+		builder.write("SELECT " + strings.Join(columns, ", "))
+	} else {
+		builder.write("SELECT COUNT(id)")
 	}
-	// Finally, deallocate the statement
-	_, err = tx.Exec("DEALLOCATE PREPARE stmt1;")
+
+	err := createCommonQuery(&builder, table, objectType, archiveQuery, queryFilter)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
-	return nil
+
+	query, args := builder.query()
+	return query, args, nil
 }
 
-// createCountQuery allows the provider to create automatically a query for the Count operation
-func createCountQuery(objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) (string, error) {
-	var queryBuffer bytes.Buffer
+// createQuery allows the provider to create automatically a query for the Query
+// operation. The rows are sorted by group first: by object type when it contains
+// a wildcard value, and by domain when the objects are grouped by domain
+func createQuery(dialect sqlDialect, table string, isDomainGrouped bool, isElementSelected bool, objectType ObjectType, isObjectTypeEqualToZero bool, archiveQuery ArchiveQuery, queryFilter QueryFilter) (string, []interface{}, error) {
+	var builder = queryBuilder{dialect: dialect}
 	// Only CompositeFilterSet type should be used
-	queryBuffer.WriteString("SELECT COUNT(id)")
+	builder.write("SELECT " + sqlObjectColumns + sqlGroupColumns)
+	// Check if we need to retrieve the element (it's also
+	// needed by the filters on the body of the objects)
+	if isElementSelected {
+		builder.write(sqlElementColumn)
+	}
 
-	err := createCommonQuery(&queryBuffer, objectType, archiveQuery, queryFilter)
+	err := createCommonQuery(&builder, table, objectType, archiveQuery, queryFilter)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return queryBuffer.String(), nil
-}
-
-// createQuery allows the provider to create automatically a query for the Query operation
-func createQuery(boolean *Boolean, objectType ObjectType, isObjectTypeEqualToZero bool, archiveQuery ArchiveQuery, queryFilter QueryFilter) (string, error) {
-	var queryBuffer bytes.Buffer
-	// Only CompositeFilterSet type should be used
-	queryBuffer.WriteString("SELECT objectInstanceIdentifier, timestamp, `details.related`, network, provider, `details.source`")
-	// Check if we need to retrieve the element and its domain
-	if boolean != nil && *boolean == true {
-		queryBuffer.WriteString(", element, domain")
+	var orderBy []string
+	if isObjectTypeEqualToZero {
+		orderBy = append(orderBy, "area", "service", "version", "number")
 	}
-	// If there's a wildcard value in one of the object type
-	// fields then we have to retrieve the entire object type
-	if isObjectTypeEqualToZero == true || (isObjectTypeEqualToZero == false && (boolean != nil && *boolean == true)) {
-		queryBuffer.WriteString(", area, service, version, number")
+	if isDomainGrouped {
+		orderBy = append(orderBy, "domain")
 	}
-
-	err := createCommonQuery(&queryBuffer, objectType, archiveQuery, queryFilter)
-	if err != nil {
-		return "", err
+	// SortOrder
+	if archiveQuery.SortOrder != nil {
+		// SortFieldName
+		var column = "timestamp"
+		if archiveQuery.SortFieldName != nil {
+			column, err = columnName(string(*archiveQuery.SortFieldName))
+			if err != nil {
+				return "", nil, err
+			}
+		}
+		// If sortOrder is false then returned values shall be sorted
+		// in descending order (ascending order is the default value)
+		if *archiveQuery.SortOrder == false {
+			column += " DESC"
+		}
+		orderBy = append(orderBy, column)
+	}
+	if len(orderBy) > 0 {
+		builder.write(" ORDER BY " + strings.Join(orderBy, ", "))
 	}
 
-	return queryBuffer.String(), nil
+	query, args := builder.query()
+	return query, args, nil
 }
 
-// createCommonQuery is a common way of generating a part of a query
-func createCommonQuery(queryBuffer *bytes.Buffer, objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) error {
+// createCommonQuery is a common way of generating a part of a query. Every
+// value is bound to a placeholder and only the columns of the archive can be
+// used in the conditions, so a value sent by a consumer can't change the query
+func createCommonQuery(builder *queryBuilder, table string, objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) error {
 	// Prepare the query for the conditions
-	queryBuffer.WriteString(" FROM " + TABLE + " WHERE")
-
-	// Attribute to check if there is already a condition before
-	var isThereAlreadyACondition = false
+	builder.write(" FROM " + table + " WHERE")
 
 	// Conditions on the object type attributes
 	// Area
 	if objectType.Area != 0 {
-		queryBuffer.WriteString(fmt.Sprintf(" area = %d", objectType.Area))
-		isThereAlreadyACondition = true
+		builder.where("area = ?", int64(objectType.Area))
 	}
 	// Service
 	if objectType.Service != 0 {
-		utils.CheckCondition(&isThereAlreadyACondition, queryBuffer)
-		queryBuffer.WriteString(fmt.Sprintf(" service = %d", objectType.Service))
+		builder.where("service = ?", int64(objectType.Service))
 	}
 	// Version
 	if objectType.Version != 0 {
-		utils.CheckCondition(&isThereAlreadyACondition, queryBuffer)
-		queryBuffer.WriteString(fmt.Sprintf(" version = %d", objectType.Version))
+		builder.where("version = ?", int64(objectType.Version))
 	}
 	// Number
 	if objectType.Number != 0 {
-		utils.CheckCondition(&isThereAlreadyACondition, queryBuffer)
-		queryBuffer.WriteString(fmt.Sprintf(" number = %d", objectType.Number))
+		builder.where("number = ?", int64(objectType.Number))
 	}
 
 	// Add archive query conditions
 	// Domain
-	if archiveQuery.Domain != nil {
-		utils.CheckCondition(&isThereAlreadyACondition, queryBuffer)
+	if archiveQuery.Domain != nil && hasDomainWildcard(archiveQuery.Domain) {
+		// The domains are preselected, they are checked again while they are read
+		builder.where("domain LIKE ? ESCAPE '!'", domainPattern(*archiveQuery.Domain))
+	} else if archiveQuery.Domain != nil {
 		domain := utils.AdaptDomainToString(*archiveQuery.Domain)
-		queryBuffer.WriteString(fmt.Sprintf(" domain = '%s'", domain))
+		builder.where("domain = ?", string(domain))
 	}
 
 	// Network
 	if archiveQuery.Network != nil {
-		utils.CheckCondition(&isThereAlreadyACondition, queryBuffer)
-		queryBuffer.WriteString(fmt.Sprintf(" network = '%s'", *archiveQuery.Network))
+		builder.where("network = ?", string(*archiveQuery.Network))
 	}
 
 	// Provider
 	if archiveQuery.Provider != nil {
-		utils.CheckCondition(&isThereAlreadyACondition, queryBuffer)
-		queryBuffer.WriteString(fmt.Sprintf(" provider = '%s'", *archiveQuery.Provider))
+		builder.where("provider = ?", string(*archiveQuery.Provider))
 	}
 
 	// Related (always have to do a query with this condition)
-	utils.CheckCondition(&isThereAlreadyACondition, queryBuffer)
-	queryBuffer.WriteString(fmt.Sprintf(" `details.related` = %d", archiveQuery.Related))
+	builder.where("`details.related` = ?", int64(archiveQuery.Related))
 
 	// Source
 	if archiveQuery.Source != nil {
-		utils.CheckCondition(&isThereAlreadyACondition, queryBuffer)
-
 		// Encode the ObjectId
 		// Create the factory
 		factory := new(FixedBinaryEncoding)
@@ -1223,19 +853,17 @@ func createCommonQuery(queryBuffer *bytes.Buffer, objectType ObjectType, archive
 		if err != nil {
 			return err
 		}
-		queryBuffer.WriteString(fmt.Sprintf(" `details.source` = %s", encoder.Body()))
+		builder.where("`details.source` = ?", encoder.Body())
 	}
 
 	// StartTime
 	if archiveQuery.StartTime != nil {
-		utils.CheckCondition(&isThereAlreadyACondition, queryBuffer)
-		queryBuffer.WriteString(fmt.Sprintf(" timestamp >= %s", time.Time(*archiveQuery.StartTime)))
+		builder.where("timestamp >= ?", time.Time(*archiveQuery.StartTime))
 	}
 
 	// EndTime
 	if archiveQuery.EndTime != nil {
-		utils.CheckCondition(&isThereAlreadyACondition, queryBuffer)
-		queryBuffer.WriteString(fmt.Sprintf(" timestamp <= %s", time.Time(*archiveQuery.EndTime)))
+		builder.where("timestamp <= ?", time.Time(*archiveQuery.EndTime))
 	}
 
 	// Add query filter conditions
@@ -1243,41 +871,16 @@ func createCommonQuery(queryBuffer *bytes.Buffer, objectType ObjectType, archive
 		compositerFilterSet := queryFilter.(*CompositeFilterSet)
 
 		for i := 0; i < compositerFilterSet.Filters.Size(); i++ {
-			utils.CheckCondition(&isThereAlreadyACondition, queryBuffer)
-			var fieldValue = (*compositerFilterSet.Filters)[i].FieldValue
-			// Transform the expresion operator
-			expressionOperator := (*compositerFilterSet.Filters)[i].Type.TransformOperator()
-			if (*compositerFilterSet.Filters)[i].Type == COM_EXPRESSIONOPERATOR_CONTAINS || (*compositerFilterSet.Filters)[i].Type == COM_EXPRESSIONOPERATOR_ICONTAINS {
-				queryBuffer.WriteString(fmt.Sprintf(" %s %s", (*compositerFilterSet.Filters)[i].FieldName,
-					expressionOperator))
-				queryBuffer.WriteString(fmt.Sprintf("%v", reflect.ValueOf(fieldValue).Elem().Interface()))
-				queryBuffer.WriteString("%'")
-			} else {
-				if fieldValue == nil {
-					queryBuffer.WriteString(fmt.Sprintf(" %s %s %s", (*compositerFilterSet.Filters)[i].FieldName,
-						expressionOperator,
-						"NULL"))
-				} else {
-					queryBuffer.WriteString(fmt.Sprintf(" %s %s", (*compositerFilterSet.Filters)[i].FieldName,
-						expressionOperator))
-					queryBuffer.WriteString(fmt.Sprintf(" %v", reflect.ValueOf(fieldValue).Elem().Interface()))
-				}
+			var filter = (*compositerFilterSet.Filters)[i]
+			column, err := columnName(string(filter.FieldName))
+			if err != nil {
+				// The filter applies to the body of the objects (see bodyFilters)
+				continue
+			}
+			err = builder.filter(column, filter.Type, attributeValue(filter.FieldValue))
+			if err != nil {
+				return err
 			}
-		}
-	}
-
-	// SortOrder
-	if archiveQuery.SortOrder != nil {
-		// SortFieldName
-		if archiveQuery.SortFieldName != nil {
-			queryBuffer.WriteString(fmt.Sprintf(" ORDER BY %s", *archiveQuery.SortFieldName))
-		} else {
-			queryBuffer.WriteString(" ORDER BY timestamp")
-		}
-		// If sortOrder is false then returned values shall be sorted
-		// in descending order (ascending order is the default value)
-		if *archiveQuery.SortOrder == false {
-			queryBuffer.WriteString(" DESC")
 		}
 	}
 
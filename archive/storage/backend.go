@@ -0,0 +1,65 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+
+	. "github.com/etiennelndr/archiveservice/data"
+)
+
+// ArchiveBackend defines the storage used by the Archive Service provider.
+// Each method implements one of the six operations of the COM archive, a
+// new storage only has to implement this interface to be used by the provider
+type ArchiveBackend interface {
+	// RetrieveInArchive retrieves a set of objects identified by their object
+	// instance identifiers (a '0' identifier retrieves all the objects). The
+	// objects are read with the returned cursor, which must be closed
+	RetrieveInArchive(objectType ObjectType, identifierList IdentifierList, objectInstanceIdentifierList LongList) (ArchiveCursor, error)
+
+	// QueryArchive retrieves the objects matching an archive query, grouped by
+	// object type and domain. The groups are read with the returned cursor,
+	// which must be closed
+	QueryArchive(boolean *Boolean, objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) (QueryCursor, error)
+
+	// CountInArchive counts the objects matching each archive query
+	CountInArchive(objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (*LongList, error)
+
+	// StoreInArchive stores new objects in the archive
+	StoreInArchive(boolean *Boolean, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*LongList, error)
+
+	// UpdateArchive updates objects already present in the archive
+	UpdateArchive(objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) error
+
+	// DeleteInArchive deletes objects from the archive (a '0' identifier
+	// deletes all the objects). The deleted objects are read in the same
+	// transaction as the delete and returned with a cursor, which must be
+	// closed
+	DeleteInArchive(objectType ObjectType, identifierList IdentifierList, longListRequest LongList) (LongList, ArchiveCursor, error)
+
+	// Close releases the resources held by the backend (e.g. its pool of
+	// connections), it is called when the provider is closed
+	Close() error
+}
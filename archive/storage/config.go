@@ -0,0 +1,283 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables overriding the configuration of the storage
+const (
+	ENV_CONFIG        = "ARCHIVE_CONFIG"
+	ENV_BACKEND       = "ARCHIVE_BACKEND"
+	ENV_DATA_SOURCE   = "ARCHIVE_DATA_SOURCE"
+	ENV_HOST          = "ARCHIVE_DB_HOST"
+	ENV_USER          = "ARCHIVE_DB_USER"
+	ENV_PASSWORD      = "ARCHIVE_DB_PASSWORD"
+	ENV_PASSWORD_FILE = "ARCHIVE_DB_PASSWORD_FILE"
+	ENV_DATABASE      = "ARCHIVE_DB_NAME"
+	ENV_TABLE         = "ARCHIVE_DB_TABLE"
+	ENV_PATH          = "ARCHIVE_SQLITE_PATH"
+	ENV_SSL_MODE      = "ARCHIVE_DB_SSL_MODE"
+	ENV_ID_ALLOCATOR  = "ARCHIVE_ID_ALLOCATOR"
+
+	ENV_MAX_OPEN_CONNS    = "ARCHIVE_DB_MAX_OPEN_CONNS"
+	ENV_MAX_IDLE_CONNS    = "ARCHIVE_DB_MAX_IDLE_CONNS"
+	ENV_CONN_MAX_LIFETIME = "ARCHIVE_DB_CONN_MAX_LIFETIME"
+)
+
+// Config holds the configuration of the storage of the archive. It can be
+// filled in the code, read from a JSON file and overridden by environment
+// variables (see LoadConfig)
+type Config struct {
+	// Backend is the name of the backend: mysql, sqlite, postgres or memory
+	Backend string `json:"backend"`
+	// DataSource is the connection string given to the database driver. When
+	// it is empty, it is created with Host, User, Password and Database
+	DataSource string `json:"dataSource"`
+	// Host is the address (host:port) of the database server
+	Host string `json:"host"`
+	// User and Password are the credentials used to connect to the database
+	User     string `json:"user"`
+	Password string `json:"password"`
+	// PasswordFile is the path of a secrets file which contains the password,
+	// it is used instead of Password when it is set
+	PasswordFile string `json:"passwordFile"`
+	// Database is the name of the database
+	Database string `json:"database"`
+	// Table is the name of the table which holds the archive
+	Table string `json:"table"`
+	// Path is the path of the SQLite database file
+	Path string `json:"path"`
+	// SSLMode is the sslmode of the connection to a PostgreSQL database
+	// (disable, require, verify-ca or verify-full). Empty keeps the default
+	// of the driver, which requires SSL
+	SSLMode string `json:"sslMode"`
+
+	// MaxOpenConns is the maximum number of connections opened by the pool
+	// of a SQL backend, and MaxIdleConns the maximum number of connections
+	// kept open while they aren't used (0 keeps the defaults of database/sql)
+	MaxOpenConns int `json:"maxOpenConns"`
+	MaxIdleConns int `json:"maxIdleConns"`
+	// ConnMaxLifetime is the maximum time a connection is reused, as
+	// understood by time.ParseDuration (e.g. "30m"). Empty means forever
+	ConnMaxLifetime string `json:"connMaxLifetime"`
+
+	// IDAllocator is the allocator of the identifiers of the objects stored
+	// without one: sequential (in each object type and domain), time or
+	// random (see NewIDAllocator)
+	IDAllocator string `json:"idAllocator"`
+}
+
+// DefaultConfig returns the configuration used when nothing is set
+func DefaultConfig() Config {
+	return Config{
+		Backend:  "mysql",
+		Database: "archive",
+		Table:    "Archive",
+		Path:     "archive.db",
+
+		IDAllocator: "sequential",
+	}
+}
+
+// LoadConfig reads the configuration from a JSON file (if path isn't empty)
+// and from the environment variables, on top of the default configuration
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	err := config.Load(path)
+	return config, err
+}
+
+// Load reads the configuration from a JSON file (if path isn't empty) and
+// then from the environment variables. Only the values which are set in the
+// file or in the environment replace the ones already in the configuration
+func (config *Config) Load(path string) error {
+	if path != "" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(content, config)
+		if err != nil {
+			return errors.New("invalid configuration file " + path + ": " + err.Error())
+		}
+	}
+
+	for env, value := range map[string]*string{
+		ENV_BACKEND:           &config.Backend,
+		ENV_DATA_SOURCE:       &config.DataSource,
+		ENV_HOST:              &config.Host,
+		ENV_USER:              &config.User,
+		ENV_PASSWORD:          &config.Password,
+		ENV_PASSWORD_FILE:     &config.PasswordFile,
+		ENV_DATABASE:          &config.Database,
+		ENV_TABLE:             &config.Table,
+		ENV_PATH:              &config.Path,
+		ENV_SSL_MODE:          &config.SSLMode,
+		ENV_ID_ALLOCATOR:      &config.IDAllocator,
+		ENV_CONN_MAX_LIFETIME: &config.ConnMaxLifetime,
+	} {
+		if envValue, ok := os.LookupEnv(env); ok {
+			*value = envValue
+		}
+	}
+
+	for env, value := range map[string]*int{
+		ENV_MAX_OPEN_CONNS: &config.MaxOpenConns,
+		ENV_MAX_IDLE_CONNS: &config.MaxIdleConns,
+	} {
+		if envValue, ok := os.LookupEnv(env); ok {
+			number, err := strconv.Atoi(envValue)
+			if err != nil {
+				return errors.New("invalid value of " + env + ": " + envValue)
+			}
+			*value = number
+		}
+	}
+
+	return nil
+}
+
+// NewBackend creates the backend described by the configuration
+func NewBackend(config Config) (ArchiveBackend, error) {
+	switch config.Backend {
+	case "mysql":
+		return NewMySQLBackend(config)
+	case "sqlite":
+		return NewSQLiteBackend(config)
+	case "postgres":
+		return NewPostgreSQLBackend(config)
+	case "memory":
+		idAllocator, err := NewIDAllocator(config.IDAllocator)
+		if err != nil {
+			return nil, err
+		}
+		backend := NewMemoryBackend()
+		backend.SetIDAllocator(idAllocator)
+		return backend, nil
+	default:
+		return nil, errors.New("unknown backend: " + config.Backend)
+	}
+}
+
+// password returns the password of the database, it's read in
+// the secrets file if there is one
+func (config Config) password() (string, error) {
+	if config.PasswordFile == "" {
+		return config.Password, nil
+	}
+	content, err := ioutil.ReadFile(config.PasswordFile)
+	if err != nil {
+		return "", err
+	}
+	// Secrets files usually end with a new line
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// mysqlDataSource returns the connection string of a MySQL database
+func (config Config) mysqlDataSource() (string, error) {
+	if config.DataSource != "" {
+		return config.DataSource, nil
+	}
+	password, err := config.password()
+	if err != nil {
+		return "", err
+	}
+
+	var address string
+	if config.Host != "" {
+		address = "tcp(" + config.Host + ")"
+	}
+	return config.User + ":" + password + "@" + address + "/" + config.Database + "?parseTime=true", nil
+}
+
+// postgresDataSource returns the connection string of a PostgreSQL database
+func (config Config) postgresDataSource() (string, error) {
+	if config.DataSource != "" {
+		return config.DataSource, nil
+	}
+	password, err := config.password()
+	if err != nil {
+		return "", err
+	}
+
+	dataSource := url.URL{
+		Scheme: "postgres",
+		Host:   config.Host,
+		Path:   "/" + config.Database,
+	}
+	if password != "" {
+		dataSource.User = url.UserPassword(config.User, password)
+	} else if config.User != "" {
+		dataSource.User = url.User(config.User)
+	}
+	if dataSource.Host == "" {
+		dataSource.Host = "localhost"
+	}
+	if config.SSLMode != "" {
+		dataSource.RawQuery = url.Values{"sslmode": []string{config.SSLMode}}.Encode()
+	}
+	return dataSource.String(), nil
+}
+
+// configurePool applies the settings of the pool of connections
+func (config Config) configurePool(db *sql.DB) error {
+	if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetime != "" {
+		lifetime, err := time.ParseDuration(config.ConnMaxLifetime)
+		if err != nil {
+			return errors.New("invalid connection lifetime: " + config.ConnMaxLifetime)
+		}
+		db.SetConnMaxLifetime(lifetime)
+	}
+	return nil
+}
+
+// table returns the name of the table, after having checked that it
+// can be safely used in a query
+func (config Config) table() (string, error) {
+	if config.Table == "" {
+		return "", errors.New("the name of the table must not be empty")
+	}
+	for _, c := range config.Table {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return "", errors.New("invalid table name: " + config.Table)
+		}
+	}
+	return config.Table, nil
+}
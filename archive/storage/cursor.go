@@ -0,0 +1,80 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+
+	. "github.com/etiennelndr/archiveservice/data"
+)
+
+// ArchiveCursor iterates over the objects returned by the Retrieve
+// operation. The objects are read from the storage one by one, so only
+// the current object is held by the cursor. The cursor must be closed
+type ArchiveCursor interface {
+	// Next moves the cursor to the next object. It returns false when
+	// there isn't any more object or when an error occurred (see Err)
+	Next() bool
+
+	// Object returns the details and the body of the current object, the
+	// body is nil when it hasn't been requested
+	Object() (*ArchiveDetails, Element)
+
+	// Err returns the error which stopped the cursor, if any
+	Err() error
+
+	// Close releases the resources of the cursor (e.g. its transaction)
+	Close() error
+}
+
+// QueryCursor iterates over the groups of objects returned by the Query
+// operation, and over the objects of the current group with the methods
+// of ArchiveCursor. An empty result is a single group without any object
+type QueryCursor interface {
+	ArchiveCursor
+
+	// NextGroup moves the cursor to the next group, skipping the objects of
+	// the current group which haven't been read. It returns false when there
+	// isn't any more group or when an error occurred (see Err)
+	NextGroup() bool
+
+	// Group returns the concrete object type of the current group and its
+	// domain (nil when the bodies of the objects haven't been requested and
+	// the domain of the query doesn't contain a wildcard). Both are nil for
+	// the group of an empty result
+	Group() (*ObjectType, *IdentifierList)
+}
+
+// archiveGroup identifies a group of objects returned by the Query operation
+type archiveGroup struct {
+	objectType ObjectType
+	domain     String
+}
+
+// hasObjectTypeWildcard checks if one of the fields of an object type is
+// the '0' wildcard value, each field matches any value independently
+func hasObjectTypeWildcard(objectType ObjectType) bool {
+	return objectType.Area == 0 || objectType.Service == 0 || objectType.Version == 0 || objectType.Number == 0
+}
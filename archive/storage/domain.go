@@ -0,0 +1,105 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	"strings"
+
+	. "github.com/ccsdsmo/malgo/mal"
+
+	"github.com/etiennelndr/archiveservice/archive/utils"
+)
+
+// DOMAIN_WILDCARD is the identifier of a query domain which matches any
+// identifier. As the last identifier of the domain, it matches the domain
+// which precedes it and all its sub-domains (e.g. fr.cnes.* matches fr.cnes
+// and fr.cnes.archiveservice.test), otherwise it matches exactly one
+// identifier (e.g. *.cnes matches fr.cnes and en.cnes)
+const DOMAIN_WILDCARD = "*"
+
+// hasDomainWildcard checks if the domain of an archive query contains
+// the '*' wildcard identifier
+func hasDomainWildcard(domain *IdentifierList) bool {
+	if domain == nil {
+		return false
+	}
+	for _, identifier := range *domain {
+		if identifier != nil && *identifier == DOMAIN_WILDCARD {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchDomain checks if the domain of an object matches the domain of an
+// archive query (or of a subscription to the events), which may contain
+// wildcard identifiers. A query domain with a NULL identifier matches
+// nothing
+func MatchDomain(queryDomain IdentifierList, domain String) bool {
+	if utils.HasNullIdentifier(queryDomain) {
+		return false
+	}
+	if !hasDomainWildcard(&queryDomain) {
+		return domain == utils.AdaptDomainToString(queryDomain)
+	}
+
+	var identifiers = strings.Split(string(domain), ".")
+	for i, identifier := range queryDomain {
+		if *identifier == DOMAIN_WILDCARD && i == len(queryDomain)-1 {
+			return true
+		}
+		if i >= len(identifiers) || (*identifier != DOMAIN_WILDCARD && string(*identifier) != identifiers[i]) {
+			return false
+		}
+	}
+	return len(identifiers) == len(queryDomain)
+}
+
+// domainPattern returns the LIKE pattern which preselects the domains
+// matched by a query domain in a SQL database, '!' is the escape
+// character of the pattern. The pattern is wider than the query domain
+// (a '%' matches several identifiers and LIKE may ignore the case), so
+// the domains are checked again with MatchDomain
+func domainPattern(queryDomain IdentifierList) string {
+	var pattern []string
+	for i, identifier := range queryDomain {
+		if *identifier == DOMAIN_WILDCARD {
+			if i == len(queryDomain)-1 {
+				// The domain itself or one of its sub-domains
+				return strings.Join(pattern, ".") + "%"
+			}
+			pattern = append(pattern, "%")
+		} else {
+			pattern = append(pattern, escapeLikePattern(string(*identifier)))
+		}
+	}
+	return strings.Join(pattern, ".")
+}
+
+// escapeLikePattern escapes the characters of a string which have
+// a meaning in a LIKE pattern, with the '!' escape character
+func escapeLikePattern(value string) string {
+	var replacer = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return replacer.Replace(value)
+}
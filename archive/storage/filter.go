@@ -0,0 +1,420 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+
+	. "github.com/etiennelndr/archiveservice/archive/constants"
+	"github.com/etiennelndr/archiveservice/archive/utils"
+	. "github.com/etiennelndr/archiveservice/data"
+)
+
+// The fields of a CompositeFilter name a column of the archive (e.g.
+// timestamp or network) or a field of the body of the objects. The body
+// can't be read by a database, so these filters are evaluated on the
+// decoded elements, whose fields are found by their names in the MAL
+// composite registered for the object type
+
+// isColumn checks if the field of a filter is a column of the archive
+func isColumn(fieldName string) bool {
+	_, err := columnName(fieldName)
+	return err == nil
+}
+
+// bodyFilters returns the filters of a query filter which apply to the
+// fields of the body of the objects
+func bodyFilters(queryFilter QueryFilter) []*CompositeFilter {
+	compositeFilterSet, ok := queryFilter.(*CompositeFilterSet)
+	if !ok || compositeFilterSet == nil || compositeFilterSet.Filters == nil {
+		return nil
+	}
+
+	var filters []*CompositeFilter
+	for _, filter := range *compositeFilterSet.Filters {
+		if !isColumn(string(filter.FieldName)) {
+			filters = append(filters, filter)
+		}
+	}
+	return filters
+}
+
+// matchBodyFilters checks if the body of an object matches all the filters
+func matchBodyFilters(element Element, filters []*CompositeFilter) (bool, error) {
+	for _, filter := range filters {
+		value, ok := bodyField(element, string(filter.FieldName))
+		if !ok {
+			return false, errors.New(string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR) + ": unknown field " + string(filter.FieldName))
+		}
+		if !matchExpression(value, filter.Type, attributeValue(filter.FieldValue)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// bodyField returns the value of a field of the body of an object. The
+// field is named by a dotted path which walks the nested composites and
+// the lists (with the index of an element), e.g. "position.x" or
+// "samples.2.value". The first letter of each name isn't case sensitive,
+// so the field Value of ValueOfSine can be named "value" as in the MAL
+// specification. A NULL composite on the path gives a NULL value
+func bodyField(element Element, path string) (interface{}, bool) {
+	value := reflect.ValueOf(element)
+	for _, name := range strings.Split(path, ".") {
+		// Go through the pointers and the abstract types
+		for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return nil, true
+			}
+			value = value.Elem()
+		}
+
+		switch {
+		case value.Kind() == reflect.Struct && !isAttributeType(value.Type()):
+			value = value.FieldByName(fieldName(name))
+			if !value.IsValid() {
+				return nil, false
+			}
+		case value.Kind() == reflect.Slice && !isAttributeType(value.Type()):
+			index, err := strconv.Atoi(name)
+			if err != nil || index < 0 {
+				return nil, false
+			}
+			if index >= value.Len() {
+				return nil, true
+			}
+			value = value.Index(index)
+		default:
+			return nil, false
+		}
+	}
+	return reflectedValue(value), true
+}
+
+// checkBodyPath checks if a dotted path names a field of a type of
+// element (see bodyField). The paths going through an abstract type can
+// only be checked on the objects, so they are accepted
+func checkBodyPath(elementType reflect.Type, path string) bool {
+	for _, name := range strings.Split(path, ".") {
+		for elementType.Kind() == reflect.Ptr {
+			elementType = elementType.Elem()
+		}
+
+		switch {
+		case elementType.Kind() == reflect.Interface:
+			return true
+		case elementType.Kind() == reflect.Struct && !isAttributeType(elementType):
+			field, ok := elementType.FieldByName(fieldName(name))
+			if !ok {
+				return false
+			}
+			elementType = field.Type
+		case elementType.Kind() == reflect.Slice && !isAttributeType(elementType):
+			index, err := strconv.Atoi(name)
+			if err != nil || index < 0 {
+				return false
+			}
+			elementType = elementType.Elem()
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// verifyBodyFilters checks the paths of the filters on the body of the
+// objects against the composite registered for the object type. They
+// can't be checked when the object type contains a wildcard value, the
+// objects are checked while they are filtered instead
+func verifyBodyFilters(objectType ObjectType, queryFilter QueryFilter) error {
+	var filters = bodyFilters(queryFilter)
+	if len(filters) == 0 || hasObjectTypeWildcard(objectType) {
+		return nil
+	}
+	elementList, err := utils.NewElementList(objectType)
+	if err != nil {
+		// The object type isn't registered, there isn't any object to filter
+		return nil
+	}
+
+	// The list is a slice of pointers to the elements
+	var elementType = reflect.TypeOf(elementList)
+	for elementType.Kind() == reflect.Ptr {
+		elementType = elementType.Elem()
+	}
+	if elementType.Kind() != reflect.Slice {
+		return nil
+	}
+	for _, filter := range filters {
+		if !checkBodyPath(elementType.Elem(), string(filter.FieldName)) {
+			return errors.New(string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR) + ": unknown field " + string(filter.FieldName))
+		}
+	}
+	return nil
+}
+
+// fieldName returns the name of the Go field of a MAL composite field
+func fieldName(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// isAttributeType checks if a type is a MAL Attribute which is a
+// struct or a slice (e.g. Time or Blob), it's the end of a path
+func isAttributeType(attributeType reflect.Type) bool {
+	var timeType = reflect.TypeOf(time.Time{})
+	return attributeType.ConvertibleTo(timeType) ||
+		(attributeType.Kind() == reflect.Slice && attributeType.Elem().Kind() == reflect.Uint8)
+}
+
+// MatchExpression evaluates an expression operator of the COM between the
+// value of a field and the value of a filter, as the backends do it for
+// the composite filters of the Query and Count operations
+func MatchExpression(value Attribute, operator ExpressionOperator, filterValue Attribute) bool {
+	return matchExpression(attributeValue(value), operator, attributeValue(filterValue))
+}
+
+// matchExpression evaluates an expression operator between a value of
+// the archive and the value of a filter:
+//   - CONTAINS and ICONTAINS search a string in another one, ICONTAINS
+//     ignores the case
+//   - a NULL value is only EQUAL to another NULL value, and it DIFFERs
+//     from any other value
+//   - the numbers are compared whatever their MAL types, the strings in
+//     lexicographic order and the times in chronological order
+//   - the Blobs and the Booleans can only be EQUAL or DIFFER
+//
+// Two values which can't be compared (e.g. a String and a Long) never
+// match, whatever the operator
+func matchExpression(value interface{}, operator ExpressionOperator, filterValue interface{}) bool {
+	if operator == COM_EXPRESSIONOPERATOR_CONTAINS || operator == COM_EXPRESSIONOPERATOR_ICONTAINS {
+		str, isString := value.(string)
+		substr, isFilterString := filterValue.(string)
+		if !isString || !isFilterString {
+			return false
+		}
+		if operator == COM_EXPRESSIONOPERATOR_ICONTAINS {
+			return strings.Contains(strings.ToLower(str), strings.ToLower(substr))
+		}
+		return strings.Contains(str, substr)
+	}
+
+	if value == nil || filterValue == nil {
+		switch operator {
+		case COM_EXPRESSIONOPERATOR_EQUAL:
+			return value == nil && filterValue == nil
+		case COM_EXPRESSIONOPERATOR_DIFFER:
+			return (value == nil) != (filterValue == nil)
+		default:
+			return false
+		}
+	}
+
+	comparison, ok := compareValues(value, filterValue)
+	if !ok {
+		return false
+	}
+	if operator != COM_EXPRESSIONOPERATOR_EQUAL && operator != COM_EXPRESSIONOPERATOR_DIFFER && !isOrdered(value) {
+		return false
+	}
+	switch operator {
+	case COM_EXPRESSIONOPERATOR_EQUAL:
+		return comparison == 0
+	case COM_EXPRESSIONOPERATOR_DIFFER:
+		return comparison != 0
+	case COM_EXPRESSIONOPERATOR_GREATER:
+		return comparison > 0
+	case COM_EXPRESSIONOPERATOR_GREATER_OR_EQUAL:
+		return comparison >= 0
+	case COM_EXPRESSIONOPERATOR_LESS:
+		return comparison < 0
+	case COM_EXPRESSIONOPERATOR_LESS_OR_EQUAL:
+		return comparison <= 0
+	default:
+		return false
+	}
+}
+
+// attributeValue converts a MAL Attribute to a Go value which can be
+// compared with the fields of a record (int64, uint64, float64, string,
+// bool, time.Time or []byte), a NULL Attribute is converted to nil
+func attributeValue(attribute Attribute) interface{} {
+	return reflectedValue(reflect.ValueOf(attribute))
+}
+
+// reflectedValue converts a MAL Attribute, given by reflection, the same
+// way as attributeValue
+func reflectedValue(value reflect.Value) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	var timeType = reflect.TypeOf(time.Time{})
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint()
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	case reflect.String:
+		return value.String()
+	case reflect.Bool:
+		return value.Bool()
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return value.Bytes()
+		}
+	case reflect.Struct:
+		if value.Type().ConvertibleTo(timeType) {
+			return value.Convert(timeType).Interface()
+		}
+	}
+	return value.Interface()
+}
+
+// compareValues compares two values returned by attributeValue or by the
+// field method of a record. It returns false if they can't be compared
+func compareValues(first interface{}, second interface{}) (int, bool) {
+	switch firstValue := first.(type) {
+	case int64, uint64, float64:
+		return compareNumbers(first, second)
+	case string:
+		secondValue, ok := second.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(firstValue, secondValue), true
+	case bool:
+		secondValue, ok := second.(bool)
+		if !ok {
+			return 0, false
+		}
+		return compareOrdered(!firstValue && secondValue, firstValue && !secondValue), true
+	case time.Time:
+		secondValue, ok := second.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		return compareOrdered(firstValue.Before(secondValue), firstValue.After(secondValue)), true
+	case []byte:
+		secondValue, ok := second.([]byte)
+		if !ok {
+			return 0, false
+		}
+		return bytes.Compare(firstValue, secondValue), true
+	default:
+		return 0, false
+	}
+}
+
+// compareNumbers compares two numbers of any type. The integers are
+// compared as integers to keep their precision, and NaN can't be compared
+func compareNumbers(first interface{}, second interface{}) (int, bool) {
+	switch firstValue := first.(type) {
+	case int64:
+		switch secondValue := second.(type) {
+		case int64:
+			return compareOrdered(firstValue < secondValue, firstValue > secondValue), true
+		case uint64:
+			if firstValue < 0 {
+				return -1, true
+			}
+			return compareOrdered(uint64(firstValue) < secondValue, uint64(firstValue) > secondValue), true
+		}
+	case uint64:
+		switch secondValue := second.(type) {
+		case uint64:
+			return compareOrdered(firstValue < secondValue, firstValue > secondValue), true
+		case int64:
+			if secondValue < 0 {
+				return 1, true
+			}
+			return compareOrdered(firstValue < uint64(secondValue), firstValue > uint64(secondValue)), true
+		}
+	}
+
+	firstNumber, ok := toFloat(first)
+	if !ok {
+		return 0, false
+	}
+	secondNumber, ok := toFloat(second)
+	if !ok || math.IsNaN(firstNumber) || math.IsNaN(secondNumber) {
+		return 0, false
+	}
+	return compareOrdered(firstNumber < secondNumber, firstNumber > secondNumber), true
+}
+
+// isOrdered checks if a value can be compared with the GREATER and LESS
+// operators: the numbers, the strings and the times
+func isOrdered(value interface{}) bool {
+	switch value.(type) {
+	case int64, uint64, float64, string, time.Time:
+		return true
+	default:
+		return false
+	}
+}
+
+// toFloat converts a numeric value to a float64
+func toFloat(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int64:
+		return float64(number), true
+	case uint64:
+		return float64(number), true
+	case float64:
+		return number, true
+	default:
+		return 0, false
+	}
+}
+
+// compareOrdered returns -1, 1 or 0 depending on which condition is true
+func compareOrdered(isLess bool, isGreater bool) int {
+	if isLess {
+		return -1
+	} else if isGreater {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+)
+
+// MAX_ID_ALLOCATION_ATTEMPTS is the number of identifiers an allocator
+// is asked for before giving up when they are all already used
+const MAX_ID_ALLOCATION_ATTEMPTS = 100
+
+// IDScope is the scope in which an object instance identifier is unique:
+// the COM identifies an object by its type, its domain and its identifier
+type IDScope struct {
+	ObjectType ObjectType
+	Domain     String
+}
+
+// IDAllocator allocates the object instance identifiers of the objects
+// stored without one. The backends check that an identifier isn't used
+// in its scope yet, and ask for another one if it is. An allocator is
+// shared by all the stores of a backend, so it must be safe to use it
+// from several goroutines
+type IDAllocator interface {
+	// AllocateID returns a new identifier, which must not be 0, for an
+	// object of the scope. last returns the highest identifier used in
+	// the scope, it's only called by the allocators which need it
+	AllocateID(scope IDScope, last func() (Long, error)) (Long, error)
+}
+
+// NewIDAllocator creates the allocator named by Config.IDAllocator:
+// sequential (the default), time or random
+func NewIDAllocator(name string) (IDAllocator, error) {
+	switch name {
+	case "", "sequential":
+		return NewSequentialIDAllocator(), nil
+	case "time":
+		return NewTimeIDAllocator(), nil
+	case "random":
+		return NewRandomIDAllocator(time.Now().UnixNano()), nil
+	default:
+		return nil, errors.New("unknown identifier allocator: " + name)
+	}
+}
+
+// allocateID asks an allocator for identifiers until it gives one which
+// isn't used in the scope
+func allocateID(allocator IDAllocator, scope IDScope, last func() (Long, error), isUsed func(Long) (bool, error)) (Long, error) {
+	for i := 0; i < MAX_ID_ALLOCATION_ATTEMPTS; i++ {
+		objectInstanceIdentifier, err := allocator.AllocateID(scope, last)
+		if err != nil {
+			return 0, err
+		}
+		if objectInstanceIdentifier == 0 {
+			continue
+		}
+		isObjInstIDUsed, err := isUsed(objectInstanceIdentifier)
+		if err != nil {
+			return 0, err
+		}
+		if !isObjInstIDUsed {
+			return objectInstanceIdentifier, nil
+		}
+	}
+	return 0, errors.New("no free object instance identifier after " + strconv.Itoa(MAX_ID_ALLOCATION_ATTEMPTS) + " attempts")
+}
+
+// SequentialIDAllocator allocates the identifiers 1, 2, 3... in each
+// scope. The next identifier follows the highest one used in the scope,
+// even by another process, and an identifier is never given twice by the
+// same allocator, even if its object has been deleted or hasn't been
+// committed yet by a concurrent store
+type SequentialIDAllocator struct {
+	mutex sync.Mutex
+	last  map[IDScope]Long
+}
+
+// NewSequentialIDAllocator creates a new sequential allocator
+func NewSequentialIDAllocator() *SequentialIDAllocator {
+	return &SequentialIDAllocator{last: make(map[IDScope]Long)}
+}
+
+// AllocateID returns the identifier following the last one of the scope
+func (allocator *SequentialIDAllocator) AllocateID(scope IDScope, last func() (Long, error)) (Long, error) {
+	allocator.mutex.Lock()
+	defer allocator.mutex.Unlock()
+
+	lastID, err := last()
+	if err != nil {
+		return 0, err
+	}
+	if allocator.last[scope] > lastID {
+		lastID = allocator.last[scope]
+	}
+	if lastID == LONG_MAX {
+		return 0, errors.New("no object instance identifier left after " + strconv.FormatInt(int64(lastID), 10))
+	}
+	lastID++
+	allocator.last[scope] = lastID
+	return lastID, nil
+}
+
+// TimeIDAllocator allocates identifiers made of the time of the store in
+// nanoseconds since the Unix epoch. They always increase, even if two
+// objects are stored at the same time, so they are unique in all scopes
+type TimeIDAllocator struct {
+	mutex  sync.Mutex
+	lastID Long
+}
+
+// NewTimeIDAllocator creates a new time-based allocator
+func NewTimeIDAllocator() *TimeIDAllocator {
+	return &TimeIDAllocator{}
+}
+
+// AllocateID returns the current time, or the last identifier plus one
+// if it isn't greater than the last identifier
+func (allocator *TimeIDAllocator) AllocateID(scope IDScope, last func() (Long, error)) (Long, error) {
+	allocator.mutex.Lock()
+	defer allocator.mutex.Unlock()
+
+	var objectInstanceIdentifier = Long(time.Now().UnixNano())
+	if objectInstanceIdentifier <= allocator.lastID {
+		objectInstanceIdentifier = allocator.lastID + 1
+	}
+	allocator.lastID = objectInstanceIdentifier
+	return objectInstanceIdentifier, nil
+}
+
+// RandomIDAllocator allocates random identifiers between 1 and LONG_MAX.
+// It has its own source of numbers, so the global source of math/rand
+// isn't seeded again by the stores
+type RandomIDAllocator struct {
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+// NewRandomIDAllocator creates a new random allocator, the same seed
+// gives the same identifiers
+func NewRandomIDAllocator(seed int64) *RandomIDAllocator {
+	return &RandomIDAllocator{random: rand.New(rand.NewSource(seed))}
+}
+
+// AllocateID returns a random identifier
+func (allocator *RandomIDAllocator) AllocateID(scope IDScope, last func() (Long, error)) (Long, error) {
+	allocator.mutex.Lock()
+	defer allocator.mutex.Unlock()
+
+	return Long(allocator.random.Int63n(int64(LONG_MAX))) + 1, nil
+}
@@ -0,0 +1,82 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+	. "github.com/ccsdsmo/malgo/mal/encoding/binary"
+
+	"github.com/etiennelndr/archiveservice/archive/utils"
+	. "github.com/etiennelndr/archiveservice/data"
+)
+
+// VerifyQuery checks the parameters of an archive query, the same way as
+// the QueryArchive method of the backends
+func VerifyQuery(objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) error {
+	return verifyParameters(objectType, archiveQuery, queryFilter)
+}
+
+// MatchObject checks if an object, which doesn't need to be in the archive,
+// matches an archive query and a query filter. It is used by the standing
+// queries to select the objects as they are stored or updated. The sort
+// fields of the archive query are ignored
+func MatchObject(objectType ObjectType, domain IdentifierList, archiveDetails ArchiveDetails, element Element,
+	queryObjectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) (bool, error) {
+	// An object without all its details can't be in the archive
+	if archiveDetails.Details.Source == nil || archiveDetails.Details.Related == nil ||
+		archiveDetails.Timestamp == nil || archiveDetails.Network == nil || archiveDetails.Provider == nil {
+		return false, nil
+	}
+
+	record, err := newMemoryRecord(archiveDetails.InstId, element, objectType, utils.AdaptDomainToString(domain), archiveDetails)
+	if err != nil {
+		return false, err
+	}
+
+	source, err := encodeSource(archiveQuery)
+	if err != nil {
+		return false, err
+	}
+
+	if !record.matchObjectType(queryObjectType) || !record.matchArchiveQuery(archiveQuery, source) {
+		return false, nil
+	}
+	return record.matchQueryFilter(queryFilter)
+}
+
+// encodeSource encodes the ObjectId of an archive query, so it can be
+// compared with the source of the records
+func encodeSource(archiveQuery ArchiveQuery) ([]byte, error) {
+	if archiveQuery.Source == nil {
+		return nil, nil
+	}
+	factory := new(FixedBinaryEncoding)
+	encoder := factory.NewEncoder(make([]byte, 0, 8192))
+	err := archiveQuery.Source.Encode(encoder)
+	if err != nil {
+		return nil, err
+	}
+	return encoder.Body(), nil
+}
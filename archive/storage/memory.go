@@ -0,0 +1,709 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+
+	. "github.com/etiennelndr/archiveservice/archive/constants"
+	"github.com/etiennelndr/archiveservice/archive/utils"
+	. "github.com/etiennelndr/archiveservice/data"
+)
+
+// MemoryBackend is the ArchiveBackend which keeps the objects of the
+// archive in memory. It doesn't need any external process so it can be
+// used by the tests or embedded in a simulator. It is safe to use it
+// from several goroutines, and its content is lost when it is released
+type MemoryBackend struct {
+	mutex       sync.RWMutex
+	records     []*memoryRecord
+	lastID      int64
+	idAllocator IDAllocator
+}
+
+// memoryRecord holds an object of the archive, with the same
+// values as a row of the table used by the SQL backends
+type memoryRecord struct {
+	id                       int64
+	objectInstanceIdentifier Long
+	element                  []byte
+	objectType               ObjectType
+	domain                   String
+	timestamp                time.Time
+	related                  Long
+	network                  Identifier
+	provider                 URI
+	source                   []byte
+}
+
+// NewMemoryBackend creates an empty in-memory archive, which allocates
+// the object instance identifiers sequentially
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{idAllocator: NewSequentialIDAllocator()}
+}
+
+// SetIDAllocator replaces the allocator of the object instance identifiers
+// (see Config.IDAllocator). It must be called before the backend is used
+func (backend *MemoryBackend) SetIDAllocator(allocator IDAllocator) {
+	backend.idAllocator = allocator
+}
+
+//======================================================================//
+//                            RETRIEVE                                  //
+//======================================================================//
+
+// RetrieveInArchive retrieves a set of objects identified by their
+// object instance identifiers
+func (backend *MemoryBackend) RetrieveInArchive(objectType ObjectType, identifierList IdentifierList, objectInstanceIdentifierList LongList) (ArchiveCursor, error) {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	// Convert domain
+	domain := utils.AdaptDomainToString(identifierList)
+
+	// Find the objects to retrieve
+	var records []memoryRecord
+	if isAllObjectInstanceIdentifiers(objectInstanceIdentifierList) {
+		for _, record := range backend.findRecords(objectType, domain) {
+			records = append(records, *record)
+		}
+		if len(records) == 0 {
+			return nil, errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
+		}
+	} else {
+		for i := 0; i < objectInstanceIdentifierList.Size(); i++ {
+			record := backend.findRecord(*objectInstanceIdentifierList[i], objectType, domain)
+			if record == nil {
+				return nil, errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
+			}
+			records = append(records, *record)
+		}
+	}
+
+	return &memoryCursor{records: records, isElementRequested: true}, nil
+}
+
+//======================================================================//
+//                              QUERY                                   //
+//======================================================================//
+
+// QueryArchive retrieves the objects matching an archive query. They are
+// grouped the same way as in the SQL backends: by concrete object type,
+// and by domain when the elements are returned or when the domain of the
+// archive query contains a wildcard
+func (backend *MemoryBackend) QueryArchive(boolean *Boolean, objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) (QueryCursor, error) {
+	// Verify the parameters
+	err := verifyParameters(objectType, archiveQuery, queryFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	records, err := backend.selectRecords(objectType, archiveQuery, queryFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	var isElementRequested = boolean != nil && *boolean == true
+	var isDomainGrouped = isElementRequested || hasDomainWildcard(archiveQuery.Domain)
+
+	var cursor = &memoryQueryCursor{memoryCursor: memoryCursor{isElementRequested: isElementRequested}}
+	// Map for the different groups
+	var groupMap = make(map[archiveGroup]int)
+
+	for _, record := range records {
+		var group = archiveGroup{objectType: record.objectType}
+		if isDomainGrouped {
+			group.domain = record.domain
+		}
+
+		index, ok := groupMap[group]
+		if !ok {
+			index = len(cursor.groups)
+			groupMap[group] = index
+
+			var queryGroup memoryQueryGroup
+			// ObjectType
+			queryGroup.objectType = &ObjectType{record.objectType.Area, record.objectType.Service, record.objectType.Version, record.objectType.Number}
+			// IdentifierList
+			if isDomainGrouped {
+				idList := utils.AdaptDomainToIdentifierList(string(record.domain))
+				queryGroup.domain = &idList
+			}
+			cursor.groups = append(cursor.groups, queryGroup)
+		}
+
+		cursor.groups[index].records = append(cursor.groups[index].records, *record)
+	}
+
+	// If no object matches the query we have to return an empty group
+	if len(cursor.groups) == 0 {
+		cursor.groups = append(cursor.groups, memoryQueryGroup{})
+	}
+
+	return cursor, nil
+}
+
+//======================================================================//
+//                              COUNT                                   //
+//======================================================================//
+
+// CountInArchive counts the objects matching each archive query
+func (backend *MemoryBackend) CountInArchive(objectType ObjectType, archiveQueryList ArchiveQueryList, queryFilterList QueryFilterList) (*LongList, error) {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	var longList = NewLongList(0)
+
+	for i := 0; i < archiveQueryList.Size(); i++ {
+		var queryFilter QueryFilter
+		if queryFilterList != nil {
+			queryFilter = queryFilterList.GetElementAt(i)
+		}
+
+		// Verify the parameters
+		err := verifyParameters(objectType, *archiveQueryList[i], queryFilter)
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := backend.selectRecords(objectType, *archiveQueryList[i], queryFilter)
+		if err != nil {
+			return nil, err
+		}
+
+		// Add this response in the long list
+		longList.AppendElement(NewLong(int64(len(records))))
+	}
+
+	return longList, nil
+}
+
+//======================================================================//
+//                              STORE                                   //
+//======================================================================//
+
+// StoreInArchive stores new objects in the archive. Nothing is stored
+// if one of the objects can't be
+func (backend *MemoryBackend) StoreInArchive(boolean *Boolean, objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) (*LongList, error) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	// Variable to return all the object instance identifiers
+	var longList *LongList
+	if boolean != nil && *boolean {
+		longList = NewLongList(0)
+	}
+
+	// Create the domain
+	domain := utils.AdaptDomainToString(identifierList)
+	// The object instance identifiers are unique per object type and domain
+	var scope = IDScope{objectType, domain}
+
+	var records []*memoryRecord
+	for i := 0; i < archiveDetailsList.Size(); i++ {
+		var objectInstanceIdentifier = archiveDetailsList[i].InstId
+		if objectInstanceIdentifier == 0 {
+			// We have to create a new and unused object instance identifier
+			var err error
+			objectInstanceIdentifier, err = allocateID(backend.idAllocator, scope,
+				func() (Long, error) {
+					return backend.lastObjectInstanceIdentifier(scope, records), nil
+				},
+				func(objectInstanceIdentifier Long) (bool, error) {
+					return backend.isObjectInstanceIdentifierInArchive(scope, objectInstanceIdentifier, records), nil
+				})
+			if err != nil {
+				return nil, err
+			}
+		} else if backend.isObjectInstanceIdentifierInArchive(scope, objectInstanceIdentifier, records) {
+			return nil, errors.New(string(COM_ERROR_DUPLICATE))
+		}
+
+		record, err := newMemoryRecord(objectInstanceIdentifier, elementList.GetElementAt(i), objectType, domain, *archiveDetailsList[i])
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+
+		if longList != nil {
+			// Insert this new object instance identifier in the returned list
+			longList.AppendElement(NewLong(int64(objectInstanceIdentifier)))
+		}
+	}
+
+	// Every object is valid, they can be added to the archive
+	for _, record := range records {
+		backend.lastID++
+		record.id = backend.lastID
+		backend.records = append(backend.records, record)
+	}
+
+	return longList, nil
+}
+
+//======================================================================//
+//                              UPDATE                                  //
+//======================================================================//
+
+// UpdateArchive updates objects already present in the archive. Nothing
+// is updated if one of the objects isn't in the archive
+func (backend *MemoryBackend) UpdateArchive(objectType ObjectType, identifierList IdentifierList, archiveDetailsList ArchiveDetailsList, elementList ElementList) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	// Create the domain
+	domain := utils.AdaptDomainToString(identifierList)
+
+	var records = make([]*memoryRecord, elementList.Size())
+	var updatedRecords = make([]*memoryRecord, elementList.Size())
+	for i := 0; i < elementList.Size(); i++ {
+		records[i] = backend.findRecord(archiveDetailsList[i].InstId, objectType, domain)
+		if records[i] == nil {
+			return errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
+		}
+
+		updatedRecord, err := newMemoryRecord(archiveDetailsList[i].InstId, elementList.GetElementAt(i), objectType, domain, *archiveDetailsList[i])
+		if err != nil {
+			return err
+		}
+		updatedRecord.id = records[i].id
+		updatedRecords[i] = updatedRecord
+	}
+
+	for i := range records {
+		*records[i] = *updatedRecords[i]
+	}
+
+	return nil
+}
+
+//======================================================================//
+//                              DELETE                                  //
+//======================================================================//
+
+// DeleteInArchive deletes objects from the archive. Nothing is
+// deleted if one of the objects isn't in the archive, the returned
+// cursor iterates over copies of the deleted records
+func (backend *MemoryBackend) DeleteInArchive(objectType ObjectType, identifierList IdentifierList, longListRequest LongList) (LongList, ArchiveCursor, error) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	// Variables to return
+	var longList LongList
+	var cursor = &memoryCursor{isElementRequested: true}
+
+	// Create the domain
+	domain := utils.AdaptDomainToString(identifierList)
+
+	var deletedRecords = make(map[*memoryRecord]bool)
+	if isAllObjectInstanceIdentifiers(longListRequest) {
+		for _, record := range backend.findRecords(objectType, domain) {
+			objectInstanceIdentifier := record.objectInstanceIdentifier
+			longList.AppendElement(&objectInstanceIdentifier)
+			cursor.records = append(cursor.records, *record)
+			deletedRecords[record] = true
+		}
+
+		if len(deletedRecords) == 0 {
+			return nil, nil, errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
+		}
+	} else {
+		for i := 0; i < longListRequest.Size(); i++ {
+			record := backend.findRecord(*longListRequest[i], objectType, domain)
+			if record == nil || deletedRecords[record] {
+				return nil, nil, errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
+			}
+			longList.AppendElement(longListRequest.GetElementAt(i))
+			cursor.records = append(cursor.records, *record)
+			deletedRecords[record] = true
+		}
+	}
+
+	// Remove the objects and set the last id to max(id)
+	var records []*memoryRecord
+	backend.lastID = 0
+	for _, record := range backend.records {
+		if !deletedRecords[record] {
+			records = append(records, record)
+			if record.id > backend.lastID {
+				backend.lastID = record.id
+			}
+		}
+	}
+	backend.records = records
+
+	return longList, cursor, nil
+}
+
+// Close does nothing, there is no resource to release
+func (backend *MemoryBackend) Close() error {
+	return nil
+}
+
+//======================================================================//
+//                           LOCAL FUNCTIONS                            //
+//======================================================================//
+
+// newMemoryRecord creates the record holding an object of the archive
+func newMemoryRecord(objectInstanceIdentifier Long, element Element, objectType ObjectType, domain String, archiveDetails ArchiveDetails) (*memoryRecord, error) {
+	// Encode the Element and the ObjectId from the ArchiveDetails, it
+	// avoids sharing them with the caller
+	encodedElement, encodedObjectID, err := utils.EncodeElements(element, *archiveDetails.Details.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &memoryRecord{
+		objectInstanceIdentifier: objectInstanceIdentifier,
+		element:                  encodedElement,
+		objectType:               objectType,
+		domain:                   domain,
+		timestamp:                time.Time(*archiveDetails.Timestamp),
+		related:                  *archiveDetails.Details.Related,
+		network:                  *archiveDetails.Network,
+		provider:                 *archiveDetails.Provider,
+		source:                   encodedObjectID,
+	}
+	return record, nil
+}
+
+// archiveDetails creates the ArchiveDetails of a record
+func (record *memoryRecord) archiveDetails() (*ArchiveDetails, error) {
+	objectID, err := utils.DecodeObjectID(record.source)
+	if err != nil {
+		return nil, err
+	}
+
+	var related = record.related
+	var network = record.network
+	var provider = record.provider
+	objectDetails := ObjectDetails{
+		Related: &related,
+		Source:  objectID,
+	}
+	return &ArchiveDetails{record.objectInstanceIdentifier, objectDetails, &network, NewFineTime(record.timestamp), &provider}, nil
+}
+
+// matchObjectType checks if the object type of a record matches an
+// object type which may contain wildcard values
+func (record *memoryRecord) matchObjectType(objectType ObjectType) bool {
+	return (objectType.Area == 0 || objectType.Area == record.objectType.Area) &&
+		(objectType.Service == 0 || objectType.Service == record.objectType.Service) &&
+		(objectType.Version == 0 || objectType.Version == record.objectType.Version) &&
+		(objectType.Number == 0 || objectType.Number == record.objectType.Number)
+}
+
+// field returns the value of a column of the record, the names are
+// the ones of the databaseFields
+func (record *memoryRecord) field(name string) (interface{}, bool) {
+	switch strings.Trim(name, "`") {
+	case "id":
+		return record.id, true
+	case "objectInstanceIdentifier":
+		return int64(record.objectInstanceIdentifier), true
+	case "element":
+		return record.element, true
+	case "area":
+		return int64(record.objectType.Area), true
+	case "service":
+		return int64(record.objectType.Service), true
+	case "version":
+		return int64(record.objectType.Version), true
+	case "number":
+		return int64(record.objectType.Number), true
+	case "domain":
+		return string(record.domain), true
+	case "timestamp":
+		return record.timestamp, true
+	case "details.related":
+		return int64(record.related), true
+	case "network":
+		return string(record.network), true
+	case "provider":
+		return string(record.provider), true
+	case "details.source":
+		return record.source, true
+	default:
+		return nil, false
+	}
+}
+
+// findRecord returns the record of an object, or nil if it isn't in the archive
+func (backend *MemoryBackend) findRecord(objectInstanceIdentifier Long, objectType ObjectType, domain String) *memoryRecord {
+	for _, record := range backend.records {
+		if record.objectInstanceIdentifier == objectInstanceIdentifier && record.objectType == objectType && record.domain == domain {
+			return record
+		}
+	}
+	return nil
+}
+
+// findRecords returns the records of all the objects with this object type and domain
+func (backend *MemoryBackend) findRecords(objectType ObjectType, domain String) []*memoryRecord {
+	var records []*memoryRecord
+	for _, record := range backend.records {
+		if record.objectType == objectType && record.domain == domain {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// isObjectInstanceIdentifierInArchive checks if an object instance identifier is already
+// used in its scope, in the archive or by one of the records which are going to be stored
+func (backend *MemoryBackend) isObjectInstanceIdentifierInArchive(scope IDScope, objectInstanceIdentifier Long, pendingRecords []*memoryRecord) bool {
+	for _, records := range [][]*memoryRecord{backend.records, pendingRecords} {
+		for _, record := range records {
+			if record.objectInstanceIdentifier == objectInstanceIdentifier && record.objectType == scope.ObjectType && record.domain == scope.Domain {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lastObjectInstanceIdentifier returns the highest object instance identifier used
+// in a scope, in the archive or by one of the records which are going to be stored
+func (backend *MemoryBackend) lastObjectInstanceIdentifier(scope IDScope, pendingRecords []*memoryRecord) Long {
+	var lastID Long
+	for _, records := range [][]*memoryRecord{backend.records, pendingRecords} {
+		for _, record := range records {
+			if record.objectInstanceIdentifier > lastID && record.objectType == scope.ObjectType && record.domain == scope.Domain {
+				lastID = record.objectInstanceIdentifier
+			}
+		}
+	}
+	return lastID
+}
+
+// selectRecords returns the records matching an archive query and a
+// query filter, sorted as requested by the archive query
+func (backend *MemoryBackend) selectRecords(objectType ObjectType, archiveQuery ArchiveQuery, queryFilter QueryFilter) ([]*memoryRecord, error) {
+	// Encode the ObjectId
+	source, err := encodeSource(archiveQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*memoryRecord
+	for _, record := range backend.records {
+		if !record.matchObjectType(objectType) || !record.matchArchiveQuery(archiveQuery, source) {
+			continue
+		}
+		isMatching, err := record.matchQueryFilter(queryFilter)
+		if err != nil {
+			return nil, err
+		}
+		if isMatching {
+			records = append(records, record)
+		}
+	}
+
+	// SortOrder
+	if archiveQuery.SortOrder != nil {
+		var sortFieldName = "timestamp"
+		if archiveQuery.SortFieldName != nil {
+			sortFieldName = string(*archiveQuery.SortFieldName)
+		}
+		// If sortOrder is false then returned values shall be sorted
+		// in descending order (ascending order is the default value)
+		var isAscending = *archiveQuery.SortOrder
+		sort.SliceStable(records, func(i, j int) bool {
+			first, _ := records[i].field(sortFieldName)
+			second, _ := records[j].field(sortFieldName)
+			comparison, _ := compareValues(first, second)
+			if isAscending {
+				return comparison < 0
+			}
+			return comparison > 0
+		})
+	}
+
+	return records, nil
+}
+
+// matchArchiveQuery checks if a record matches the common parts of an archive query
+func (record *memoryRecord) matchArchiveQuery(archiveQuery ArchiveQuery, source []byte) bool {
+	if archiveQuery.Domain != nil && !MatchDomain(*archiveQuery.Domain, record.domain) {
+		return false
+	}
+	if archiveQuery.Network != nil && record.network != *archiveQuery.Network {
+		return false
+	}
+	if archiveQuery.Provider != nil && record.provider != *archiveQuery.Provider {
+		return false
+	}
+	// Related (always have to check this condition)
+	if record.related != archiveQuery.Related {
+		return false
+	}
+	if source != nil && !bytes.Equal(record.source, source) {
+		return false
+	}
+	if archiveQuery.StartTime != nil && record.timestamp.Before(time.Time(*archiveQuery.StartTime)) {
+		return false
+	}
+	if archiveQuery.EndTime != nil && record.timestamp.After(time.Time(*archiveQuery.EndTime)) {
+		return false
+	}
+	return true
+}
+
+// matchQueryFilter checks if a record matches all the filters of a query
+// filter. A filter applies to a column of the archive if its field is one of
+// them, otherwise to a field of the body of the object
+func (record *memoryRecord) matchQueryFilter(queryFilter QueryFilter) (bool, error) {
+	compositeFilterSet, ok := queryFilter.(*CompositeFilterSet)
+	if !ok || compositeFilterSet == nil || compositeFilterSet.Filters == nil {
+		return true, nil
+	}
+
+	var element Element
+	for _, filter := range *compositeFilterSet.Filters {
+		value, ok := record.field(string(filter.FieldName))
+		if !ok {
+			// The element is only decoded if a filter needs it
+			if element == nil {
+				var err error
+				element, err = utils.DecodeElement(record.element)
+				if err != nil {
+					return false, err
+				}
+			}
+			value, ok = bodyField(element, string(filter.FieldName))
+			if !ok {
+				return false, errors.New(string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR) + ": unknown field " + string(filter.FieldName))
+			}
+		}
+		if !matchExpression(value, filter.Type, attributeValue(filter.FieldValue)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// isAllObjectInstanceIdentifiers checks if a list of object instance
+// identifiers contains the '0' wildcard value
+func isAllObjectInstanceIdentifiers(objectInstanceIdentifierList LongList) bool {
+	for i := 0; i < objectInstanceIdentifierList.Size(); i++ {
+		if *objectInstanceIdentifierList[i] == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// memoryCursor iterates over copies of the records read by an operation,
+// so the archive can be changed while the cursor is used. All the records
+// are copied when the cursor is created, its memory isn't bounded
+type memoryCursor struct {
+	records            []memoryRecord
+	index              int
+	isElementRequested bool
+	archiveDetails     *ArchiveDetails
+	element            Element
+	err                error
+}
+
+// Next moves the cursor to the next record and decodes it
+func (cursor *memoryCursor) Next() bool {
+	if cursor.err != nil || cursor.index >= len(cursor.records) {
+		return false
+	}
+	record := &cursor.records[cursor.index]
+	cursor.index++
+
+	cursor.archiveDetails, cursor.err = record.archiveDetails()
+	if cursor.err != nil {
+		return false
+	}
+	cursor.element = nil
+	if cursor.isElementRequested {
+		cursor.element, cursor.err = utils.DecodeElement(record.element)
+		if cursor.err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Object returns the current object
+func (cursor *memoryCursor) Object() (*ArchiveDetails, Element) {
+	return cursor.archiveDetails, cursor.element
+}
+
+// Err returns the error which stopped the cursor
+func (cursor *memoryCursor) Err() error {
+	return cursor.err
+}
+
+// Close does nothing, the records are released with the cursor
+func (cursor *memoryCursor) Close() error {
+	return nil
+}
+
+// memoryQueryGroup holds the records of a group of the Query operation
+type memoryQueryGroup struct {
+	objectType *ObjectType
+	domain     *IdentifierList
+	records    []memoryRecord
+}
+
+// memoryQueryCursor iterates over the groups of the Query operation
+type memoryQueryCursor struct {
+	memoryCursor
+	groups     []memoryQueryGroup
+	groupIndex int
+}
+
+// NextGroup moves the cursor to the first record of the next group
+func (cursor *memoryQueryCursor) NextGroup() bool {
+	if cursor.err != nil || cursor.groupIndex >= len(cursor.groups) {
+		return false
+	}
+	cursor.records = cursor.groups[cursor.groupIndex].records
+	cursor.index = 0
+	cursor.groupIndex++
+	return true
+}
+
+// Group returns the object type and the domain of the current group
+func (cursor *memoryQueryCursor) Group() (*ObjectType, *IdentifierList) {
+	if cursor.groupIndex == 0 {
+		return nil, nil
+	}
+	group := cursor.groups[cursor.groupIndex-1]
+	return group.objectType, group.domain
+}
@@ -0,0 +1,220 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxReportedDuplicates is the maximum number of duplicate objects listed
+// in the error of the migration which makes the identifiers unique
+const maxReportedDuplicates = 10
+
+// SCHEMA_VERSION_TABLE is the table which holds the migrations applied to
+// the tables of the archives stored in a database
+const SCHEMA_VERSION_TABLE = "schema_version"
+
+// migration is a change of the schema of an archive. Its statements are
+// executed once, then the version is stored in the schema_version table.
+// The migrations of a dialect are ordered by version and a migration
+// must never be changed once it has been released: new columns or
+// indexes are added with a new migration at the end of the list. The check
+// of a migration (if any) is executed before its statements, in the same
+// transaction, to report the data which would make the statements fail
+type migration struct {
+	version     int
+	description string
+	statements  []string
+	check       func(tx *sql.Tx) error
+}
+
+// migrate applies the migrations of the dialect which haven't been applied
+// to the table yet. It is called each time the backend is created, so an
+// up-to-date archive is left as it is
+func (backend *SQLBackend) migrate() error {
+	var migrations = backend.dialect.migrations(backend.table)
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version <= migrations[i-1].version {
+			return errors.New("migrations must be ordered by version: " + strconv.Itoa(migrations[i].version))
+		}
+	}
+
+	// Create the table of the versions
+	_, err := backend.db.Exec(`CREATE TABLE IF NOT EXISTS ` + SCHEMA_VERSION_TABLE + ` (
+	tableName VARCHAR(64) NOT NULL,
+	version INTEGER NOT NULL,
+	description VARCHAR(255),
+	appliedAt TIMESTAMP NULL,
+	PRIMARY KEY (tableName, version)
+)`)
+	if err != nil {
+		return err
+	}
+
+	currentVersion, err := backend.schemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if migration.version <= currentVersion {
+			continue
+		}
+		err = backend.applyMigration(migration)
+		if err != nil {
+			return errors.New("migration " + strconv.Itoa(migration.version) + " (" + migration.description + ") failed: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// schemaVersion returns the version of the last migration applied
+// to the table, 0 if none has been applied
+func (backend *SQLBackend) schemaVersion() (int, error) {
+	var version sql.NullInt64
+	err := backend.db.QueryRow(backend.dialect.rebind("SELECT MAX(version) FROM "+SCHEMA_VERSION_TABLE+" WHERE tableName = ?"), backend.table).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// applyMigration executes the statements of a migration and stores its
+// version in the same transaction. The databases which can't roll back
+// a change of the schema (e.g. MySQL) commit each statement, that's why
+// the statements of a migration have to be idempotent
+func (backend *SQLBackend) applyMigration(migration migration) error {
+	tx, err := backend.createTransaction()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if migration.check != nil {
+		err = migration.check(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, statement := range migration.statements {
+		_, err = tx.Exec(statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(backend.dialect.rebind("INSERT INTO "+SCHEMA_VERSION_TABLE+" (tableName, version, description, appliedAt) VALUES (?, ?, ?, ?)"),
+		backend.table, migration.version, migration.description, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	// Commit changes
+	return tx.Commit()
+}
+
+// indexStatements creates the indexes used by the operations of the
+// archive, for the databases which support CREATE INDEX IF NOT EXISTS:
+// the lookups of an object by its type, domain and instance identifier
+// (whose prefix is used to find all the objects of a type and a domain),
+// the check of a new instance identifier and the conditions of the queries
+func indexStatements(table string) []string {
+	return []string{
+		"CREATE INDEX IF NOT EXISTS " + table + "_object ON " + table + " (area, service, version, number, domain, objectInstanceIdentifier)",
+		"CREATE INDEX IF NOT EXISTS " + table + "_instance ON " + table + " (objectInstanceIdentifier)",
+		"CREATE INDEX IF NOT EXISTS " + table + "_timestamp ON " + table + " (timestamp)",
+		"CREATE INDEX IF NOT EXISTS " + table + "_network ON " + table + " (network)",
+		"CREATE INDEX IF NOT EXISTS " + table + "_provider ON " + table + " (provider)",
+	}
+}
+
+// uniqueObjectStatements replaces the index of the lookups of an object by
+// a unique index, so that two stores can't give the same instance identifier
+// to two objects of the same type and domain (the COM identifies an object
+// by these three values)
+func uniqueObjectStatements(table string) []string {
+	return []string{
+		"DROP INDEX IF EXISTS " + table + "_object",
+		"CREATE UNIQUE INDEX IF NOT EXISTS " + table + "_object ON " + table + " (area, service, version, number, domain, objectInstanceIdentifier)",
+	}
+}
+
+// duplicateObjectsCheck checks that the unique index of the object type,
+// domain and instance identifier can be created: an archive filled before
+// this index may hold several objects with the same identifier in a type
+// and a domain. A migration never deletes objects, the duplicates are
+// reported so that they can be removed (or given a new identifier) before
+// the backend is created again
+func duplicateObjectsCheck(table string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT area, service, version, number, domain, objectInstanceIdentifier, COUNT(*) FROM " + table +
+			" GROUP BY area, service, version, number, domain, objectInstanceIdentifier HAVING COUNT(*) > 1" +
+			" ORDER BY area, service, version, number, domain, objectInstanceIdentifier")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var duplicates []string
+		var count int
+		for rows.Next() {
+			var area, service, version, number, objectInstanceIdentifier sql.NullInt64
+			var domain sql.NullString
+			var objects int64
+			err = rows.Scan(&area, &service, &version, &number, &domain, &objectInstanceIdentifier, &objects)
+			if err != nil {
+				return err
+			}
+			count++
+			if len(duplicates) < maxReportedDuplicates {
+				duplicates = append(duplicates, "object type "+
+					strconv.FormatInt(area.Int64, 10)+"."+
+					strconv.FormatInt(service.Int64, 10)+"."+
+					strconv.FormatInt(version.Int64, 10)+"."+
+					strconv.FormatInt(number.Int64, 10)+
+					" in domain '"+domain.String+"'"+
+					" with identifier "+strconv.FormatInt(objectInstanceIdentifier.Int64, 10)+
+					" ("+strconv.FormatInt(objects, 10)+" objects)")
+			}
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+
+		if count > len(duplicates) {
+			duplicates = append(duplicates, "...")
+		}
+		return errors.New(strconv.Itoa(count) + " object instance identifiers are used by several objects of the same type and domain in " + table +
+			", remove the duplicates before the unique index is created: " + strings.Join(duplicates, ", "))
+	}
+}
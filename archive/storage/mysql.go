@@ -0,0 +1,187 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	// Init mysql driver
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// mysqlDialect holds the specificities of MySQL (and MariaDB)
+type mysqlDialect struct {
+	dataSource string
+}
+
+// NewMySQLBackend creates a new backend which stores the objects
+// of the archive in a MySQL (or MariaDB) database. The schema of
+// the archive is created or upgraded if needed
+func NewMySQLBackend(config Config) (*SQLBackend, error) {
+	dataSource, err := config.mysqlDataSource()
+	if err != nil {
+		return nil, err
+	}
+	return newSQLBackend(mysqlDialect{dataSource}, config)
+}
+
+func (mysqlDialect) driverName() string {
+	return "mysql"
+}
+
+func (dialect mysqlDialect) dataSourceName() string {
+	return dialect.dataSource
+}
+
+// rebind returns the query as it is, it's already written for MySQL
+func (mysqlDialect) rebind(query string) string {
+	return query
+}
+
+// contains searches the string with INSTR instead of a LIKE pattern, so
+// '%' and '_' are searched as they are. The strings are compared as binary
+// strings when the case matters, the collation of the table ignores it
+func (mysqlDialect) contains(column string, isCaseSensitive bool) string {
+	if isCaseSensitive {
+		return "INSTR(CAST(" + column + " AS BINARY), CAST(? AS BINARY)) > 0"
+	}
+	return "INSTR(LOWER(" + column + "), LOWER(?)) > 0"
+}
+
+// resetAutoIncrement takes the maximum id in the database and set the
+// AUTO_INCREMENT at this value (actually it's this value to which we added 1)
+func (mysqlDialect) resetAutoIncrement(tx *sql.Tx, table string) error {
+	// Create a value to store the maximum id (to which we added 1)
+	_, err := tx.Exec("SELECT @max := max(id)+1 FROM " + table)
+	if err != nil {
+		return err
+	}
+	// Create the statement (in our case, we use an "alter table" statement)
+	_, err = tx.Exec("SET @alter_statement = CONCAT('ALTER TABLE " + table + " AUTO_INCREMENT = ', @max)")
+	if err != nil {
+		return err
+	}
+	// Prepare the statement
+	_, err = tx.Exec("PREPARE stmt1 FROM @alter_statement;")
+	if err != nil {
+		return err
+	}
+	// Then execute the statement
+	_, err = tx.Exec("EXECUTE stmt1")
+	if err != nil {
+		return err
+	}
+	// Finally, deallocate the statement
+	_, err = tx.Exec("DEALLOCATE PREPARE stmt1;")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// migrations creates the table of the archive. The first migration is the
+// table of the former archive.sql dump, so an archive created with this
+// dump is kept as it is
+func (mysqlDialect) migrations(table string) []migration {
+	return []migration{
+		{1, "create the table of the archive", []string{
+			"CREATE TABLE IF NOT EXISTS " + table + ` (
+	id int(10) unsigned NOT NULL AUTO_INCREMENT,
+	objectInstanceIdentifier bigint(20) unsigned DEFAULT NULL,
+	element blob,
+	area smallint(6) DEFAULT NULL,
+	service smallint(6) DEFAULT NULL,
+	version tinyint(4) DEFAULT NULL,
+	number smallint(6) DEFAULT NULL,
+	domain text,
+	timestamp datetime DEFAULT NULL,
+	` + "`details.related`" + ` bigint(20) DEFAULT NULL,
+	network text,
+	provider text,
+	` + "`details.source`" + ` blob,
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+		}, nil},
+		// A TEXT column can't be fully indexed, so domain, network and
+		// provider become VARCHAR columns. MySQL commits each change of
+		// the schema, so an index is only added if it doesn't exist yet
+		{2, "index the object type, domain, instance identifier and timestamp", append([]string{
+			"ALTER TABLE " + table + " MODIFY domain VARCHAR(255), MODIFY network VARCHAR(255), MODIFY provider VARCHAR(255)",
+		}, mysqlIndexStatements(table)...), longValuesCheck(table)},
+		{3, "make the object instance identifiers unique in each object type and domain", []string{
+			"ALTER TABLE " + table +
+				" DROP INDEX " + table + "_object," +
+				" ADD UNIQUE INDEX " + table + "_object (area, service, version, number, domain, objectInstanceIdentifier)",
+		}, duplicateObjectsCheck(table)},
+	}
+}
+
+// mysqlIndexStatements creates the same indexes as indexStatements. MySQL
+// doesn't support CREATE INDEX IF NOT EXISTS, so each ALTER statement is
+// chosen by a lookup in information_schema.statistics and executed as a
+// prepared statement (like in resetAutoIncrement), it does nothing if the
+// index already exists
+func mysqlIndexStatements(table string) []string {
+	var indexes = []struct{ name, columns string }{
+		{table + "_object", "area, service, version, number, domain, objectInstanceIdentifier"},
+		{table + "_instance", "objectInstanceIdentifier"},
+		{table + "_timestamp", "timestamp"},
+		{table + "_network", "network"},
+		{table + "_provider", "provider"},
+	}
+
+	var statements []string
+	for _, index := range indexes {
+		statements = append(statements,
+			"SET @add_index = IF((SELECT COUNT(*) FROM information_schema.statistics"+
+				" WHERE table_schema = DATABASE() AND table_name = '"+table+"' AND index_name = '"+index.name+"') > 0,"+
+				" 'DO 0', 'ALTER TABLE "+table+" ADD INDEX "+index.name+" ("+index.columns+")')",
+			"PREPARE stmt1 FROM @add_index",
+			"EXECUTE stmt1",
+			"DEALLOCATE PREPARE stmt1")
+	}
+	return statements
+}
+
+// longValuesCheck checks that the domains, networks and providers of the
+// archive fit in the VARCHAR(255) columns which replace the TEXT columns:
+// MySQL may truncate them without an error. The lengths are counted in
+// characters, like the length of a VARCHAR column
+func longValuesCheck(table string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		var count int64
+		err := tx.QueryRow("SELECT COUNT(*) FROM " + table +
+			" WHERE CHAR_LENGTH(domain) > 255 OR CHAR_LENGTH(network) > 255 OR CHAR_LENGTH(provider) > 255").Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New(strconv.FormatInt(count, 10) + " objects of " + table +
+				" have a domain, a network or a provider longer than 255 characters, shorten them before the columns are changed")
+		}
+		return nil
+	}
+}
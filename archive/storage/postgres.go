@@ -0,0 +1,129 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"strconv"
+
+	// Init postgres driver
+	_ "github.com/lib/pq"
+)
+
+// postgresDialect holds the specificities of PostgreSQL
+type postgresDialect struct {
+	dataSource string
+}
+
+// NewPostgreSQLBackend creates a new backend which stores the objects of
+// the archive in a PostgreSQL database. The schema of the archive is
+// created or upgraded if needed
+func NewPostgreSQLBackend(config Config) (*SQLBackend, error) {
+	dataSource, err := config.postgresDataSource()
+	if err != nil {
+		return nil, err
+	}
+	return newSQLBackend(postgresDialect{dataSource}, config)
+}
+
+func (postgresDialect) driverName() string {
+	return "postgres"
+}
+
+func (dialect postgresDialect) dataSourceName() string {
+	return dialect.dataSource
+}
+
+// rebind replaces the '?' placeholders by '$1', '$2', ... and the
+// backquotes around the columns by double quotes. Nothing is replaced
+// inside a string literal or a double quoted identifier (a quote is
+// escaped by doubling it, which leaves then enters the literal again)
+func (postgresDialect) rebind(query string) string {
+	var buffer bytes.Buffer
+	var isInLiteral = false
+	var isInIdentifier = false
+	var countPlaceholders = 0
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' && !isInIdentifier:
+			isInLiteral = !isInLiteral
+			buffer.WriteByte(c)
+		case c == '"' && !isInLiteral:
+			isInIdentifier = !isInIdentifier
+			buffer.WriteByte(c)
+		case isInLiteral || isInIdentifier:
+			buffer.WriteByte(c)
+		case c == '?':
+			countPlaceholders++
+			buffer.WriteString("$" + strconv.Itoa(countPlaceholders))
+		case c == '`':
+			buffer.WriteByte('"')
+		default:
+			buffer.WriteByte(c)
+		}
+	}
+	return buffer.String()
+}
+
+// contains searches the string with STRPOS, which doesn't give a
+// meaning to '%' and '_' as LIKE does
+func (postgresDialect) contains(column string, isCaseSensitive bool) string {
+	if isCaseSensitive {
+		return "STRPOS(" + column + ", ?) > 0"
+	}
+	return "STRPOS(LOWER(" + column + "), LOWER(?)) > 0"
+}
+
+// resetAutoIncrement sets the sequence of the id column so that
+// the next id is max(id)+1
+func (postgresDialect) resetAutoIncrement(tx *sql.Tx, table string) error {
+	_, err := tx.Exec("SELECT setval(pg_get_serial_sequence('" + table + "', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM " + table)
+	return err
+}
+
+// migrations creates the same table than the other SQL backends
+func (postgresDialect) migrations(table string) []migration {
+	return []migration{
+		{1, "create the table of the archive", []string{
+			`CREATE TABLE IF NOT EXISTS ` + table + ` (
+	id SERIAL PRIMARY KEY,
+	objectInstanceIdentifier BIGINT,
+	element BYTEA,
+	area SMALLINT,
+	service SMALLINT,
+	version SMALLINT,
+	number SMALLINT,
+	domain TEXT,
+	timestamp TIMESTAMP,
+	"details.related" BIGINT,
+	network TEXT,
+	provider TEXT,
+	"details.source" BYTEA
+)`,
+		}, nil},
+		{2, "index the object type, domain, instance identifier and timestamp", indexStatements(table), nil},
+		{3, "make the object instance identifiers unique in each object type and domain", uniqueObjectStatements(table), duplicateObjectsCheck(table)},
+	}
+}
@@ -0,0 +1,68 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	"testing"
+)
+
+func TestPostgresRebind(t *testing.T) {
+	var tests = []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT * FROM Archive", "SELECT * FROM Archive"},
+		{"SELECT * FROM Archive WHERE area = ? AND service = ?", "SELECT * FROM Archive WHERE area = $1 AND service = $2"},
+		{"SELECT `details.related` FROM Archive", `SELECT "details.related" FROM Archive`},
+		{"SELECT * FROM Archive WHERE network = 'why?' AND area = ?", "SELECT * FROM Archive WHERE network = 'why?' AND area = $1"},
+		{"SELECT * FROM Archive WHERE network = '`network`' AND `details.related` = ?", `SELECT * FROM Archive WHERE network = '` + "`network`" + `' AND "details.related" = $1`},
+		{"SELECT * FROM Archive WHERE network = 'it''s ?' AND area = ?", "SELECT * FROM Archive WHERE network = 'it''s ?' AND area = $1"},
+		{"SELECT \"what?\" FROM Archive WHERE area = ?", "SELECT \"what?\" FROM Archive WHERE area = $1"},
+		{"SELECT \"it's\" FROM Archive WHERE area = ? AND network = '?'", "SELECT \"it's\" FROM Archive WHERE area = $1 AND network = '?'"},
+		{"SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?", "SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10"},
+	}
+
+	for _, test := range tests {
+		if result := (postgresDialect{}).rebind(test.query); result != test.expected {
+			t.Errorf("rebind(%q) = %q, expected %q", test.query, result, test.expected)
+		}
+	}
+}
+
+func TestPostgresDataSource(t *testing.T) {
+	config := DefaultConfig()
+	config.User = "archiveService"
+	config.Password = "secret"
+	config.Host = "db:5432"
+	dataSource, err := config.postgresDataSource()
+	if err != nil || dataSource != "postgres://archiveService:secret@db:5432/archive" {
+		t.Errorf("postgresDataSource() = %q, %v", dataSource, err)
+	}
+
+	config.SSLMode = "disable"
+	dataSource, err = config.postgresDataSource()
+	if err != nil || dataSource != "postgres://archiveService:secret@db:5432/archive?sslmode=disable" {
+		t.Errorf("postgresDataSource() = %q, %v", dataSource, err)
+	}
+}
@@ -0,0 +1,107 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	. "github.com/etiennelndr/archiveservice/archive/constants"
+	. "github.com/etiennelndr/archiveservice/data"
+)
+
+// queryBuilder creates a query with '?' placeholders. The values of the
+// query are never written in it, they are kept apart and bound by the
+// driver when the query is executed
+type queryBuilder struct {
+	dialect                  sqlDialect
+	buffer                   bytes.Buffer
+	args                     []interface{}
+	isThereAlreadyACondition bool
+}
+
+// write appends a part of the query which doesn't contain any value
+func (builder *queryBuilder) write(part string) {
+	builder.buffer.WriteString(part)
+}
+
+// where appends a condition to the WHERE clause, each '?' placeholder
+// of the condition is bound to one of the values
+func (builder *queryBuilder) where(condition string, values ...interface{}) {
+	if builder.isThereAlreadyACondition {
+		builder.buffer.WriteString(" AND")
+	} else {
+		builder.isThereAlreadyACondition = true
+	}
+	builder.buffer.WriteString(" " + condition)
+	builder.args = append(builder.args, values...)
+}
+
+// filter appends the condition of an expression operator between a column
+// and a value. It follows the evaluation of matchExpression: a NULL value
+// is only EQUAL to a NULL column, and a NULL column DIFFERs from any value
+func (builder *queryBuilder) filter(column string, operator ExpressionOperator, value interface{}) error {
+	switch operator {
+	case COM_EXPRESSIONOPERATOR_CONTAINS, COM_EXPRESSIONOPERATOR_ICONTAINS:
+		builder.where(builder.dialect.contains(column, operator == COM_EXPRESSIONOPERATOR_CONTAINS), value)
+	case COM_EXPRESSIONOPERATOR_EQUAL:
+		if value == nil {
+			builder.where(column + " IS NULL")
+		} else {
+			builder.where(column+" = ?", value)
+		}
+	case COM_EXPRESSIONOPERATOR_DIFFER:
+		if value == nil {
+			builder.where(column + " IS NOT NULL")
+		} else {
+			builder.where("("+column+" != ? OR "+column+" IS NULL)", value)
+		}
+	default:
+		sqlOperator := operator.TransformOperator()
+		if sqlOperator == "" {
+			return errors.New(string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR) + ": unknown expression operator")
+		}
+		builder.where(column+" "+string(sqlOperator)+" ?", value)
+	}
+	return nil
+}
+
+// query returns the query and the values bound to its placeholders
+func (builder *queryBuilder) query() (string, []interface{}) {
+	return builder.buffer.String(), builder.args
+}
+
+// columnName returns the column of the database named by a field of a
+// query (the backquotes around the name are optional). Only the columns
+// of the archive can be used, so the name can safely be put in a query
+func columnName(fieldName string) (string, error) {
+	var name = strings.Trim(fieldName, "`")
+	for _, column := range databaseFields {
+		if strings.Trim(column, "`") == name {
+			return column, nil
+		}
+	}
+	return "", errors.New(string(ARCHIVE_SERVICE_QUERY_QUERY_FILTER_ERROR) + ": unknown field " + fieldName)
+}
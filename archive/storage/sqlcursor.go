@@ -0,0 +1,436 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+
+	"github.com/etiennelndr/archiveservice/archive/utils"
+	. "github.com/etiennelndr/archiveservice/data"
+)
+
+// Columns read by the cursors, in the order expected by sqlObject.scan
+const (
+	sqlObjectColumns = "objectInstanceIdentifier, timestamp, `details.related`, network, provider, `details.source`"
+	sqlGroupColumns  = ", area, service, version, number, domain"
+	sqlElementColumn = ", element"
+)
+
+// rowScanner is implemented by sql.Row and sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// sqlObject holds the columns of an object read in the database
+type sqlObject struct {
+	objectInstanceIdentifier Long
+	timestamp                time.Time
+	related                  Long
+	network                  Identifier
+	provider                 URI
+	encodedObjectId          []byte
+	objectType               ObjectType
+	domain                   String
+	encodedElement           []byte
+	// element is the decoded element, once it has been decoded
+	element Element
+}
+
+// scan reads the columns of an object, the columns of its group and
+// its element are only read if they have been selected
+func (object *sqlObject) scan(scanner rowScanner, isGroupSelected bool, isElementSelected bool) error {
+	var dest = []interface{}{
+		&object.objectInstanceIdentifier,
+		&object.timestamp,
+		&object.related,
+		&object.network,
+		&object.provider,
+		&object.encodedObjectId,
+	}
+	if isGroupSelected {
+		dest = append(dest,
+			&object.objectType.Area,
+			&object.objectType.Service,
+			&object.objectType.Version,
+			&object.objectType.Number,
+			&object.domain)
+	}
+	if isElementSelected {
+		dest = append(dest, &object.encodedElement)
+	}
+	return scanner.Scan(dest...)
+}
+
+// decode creates the ArchiveDetails of the object and decodes its element
+// if it has been selected
+func (object *sqlObject) decode(isElementSelected bool) (*ArchiveDetails, Element, error) {
+	// Decode the ObjectId for the ArchiveDetails
+	objectId, err := utils.DecodeObjectID(object.encodedObjectId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Create the ArchiveDetails
+	var related = object.related
+	var network = object.network
+	var provider = object.provider
+	objectDetails := ObjectDetails{&related, objectId}
+	archiveDetails := &ArchiveDetails{
+		object.objectInstanceIdentifier,
+		objectDetails,
+		&network,
+		NewFineTime(object.timestamp),
+		&provider,
+	}
+
+	if !isElementSelected {
+		return archiveDetails, nil, nil
+	}
+	err = object.decodeElement()
+	if err != nil {
+		return nil, nil, err
+	}
+	return archiveDetails, object.element, nil
+}
+
+// decodeElement decodes the element of the object if it isn't decoded yet
+func (object *sqlObject) decodeElement() error {
+	if object.element != nil {
+		return nil
+	}
+	element, err := utils.DecodeElement(object.encodedElement)
+	if err != nil {
+		return err
+	}
+	object.element = element
+	return nil
+}
+
+// countMatchingObjects counts the objects selected by a query which match
+// a query domain with wildcard identifiers (if it isn't nil) and the
+// filters on the body of the objects. The query selects the domain and
+// the element of the objects, in this order, when they are checked
+func countMatchingObjects(tx *sql.Tx, query string, args []interface{}, queryDomain *IdentifierList, filters []*CompositeFilter) (int64, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		var object sqlObject
+		var dest []interface{}
+		if queryDomain != nil {
+			dest = append(dest, &object.domain)
+		}
+		if len(filters) > 0 {
+			dest = append(dest, &object.encodedElement)
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			return 0, err
+		}
+		if queryDomain != nil && !MatchDomain(*queryDomain, object.domain) {
+			continue
+		}
+		if len(filters) > 0 {
+			err = object.decodeElement()
+			if err != nil {
+				return 0, err
+			}
+			isMatching, err := matchBodyFilters(object.element, filters)
+			if err != nil {
+				return 0, err
+			}
+			if !isMatching {
+				continue
+			}
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// group returns the group of the Query operation the object belongs to
+func (object *sqlObject) group(isDomainGrouped bool) archiveGroup {
+	var group = archiveGroup{objectType: object.objectType}
+	if isDomainGrouped {
+		group.domain = object.domain
+	}
+	return group
+}
+
+//======================================================================//
+//                            RETRIEVE                                  //
+//======================================================================//
+
+// sqlRetrieveCursor reads the objects of the Retrieve operation, from the
+// rows of all the objects of a domain or one object instance identifier
+// after another
+type sqlRetrieveCursor struct {
+	backend                      *SQLBackend
+	tx                           *sql.Tx
+	rows                         *sql.Rows
+	objectType                   ObjectType
+	domain                       String
+	objectInstanceIdentifierList LongList
+	count                        int
+	archiveDetails               *ArchiveDetails
+	element                      Element
+	err                          error
+}
+
+// Next reads the next object in the database
+func (cursor *sqlRetrieveCursor) Next() bool {
+	if cursor.err != nil {
+		return false
+	}
+
+	var object sqlObject
+	if cursor.rows != nil {
+		if !cursor.rows.Next() {
+			cursor.err = cursor.rows.Err()
+			if cursor.err == nil && cursor.count == 0 {
+				cursor.err = errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
+			}
+			return false
+		}
+		cursor.err = object.scan(cursor.rows, false, true)
+	} else {
+		if cursor.count >= cursor.objectInstanceIdentifierList.Size() {
+			return false
+		}
+		row := cursor.tx.QueryRow(cursor.backend.dialect.rebind("SELECT "+sqlObjectColumns+sqlElementColumn+" FROM "+cursor.backend.table+" WHERE objectInstanceIdentifier = ? AND area = ? AND service = ? AND version = ? AND number = ? AND domain = ?"),
+			*cursor.objectInstanceIdentifierList[cursor.count],
+			cursor.objectType.Area,
+			cursor.objectType.Service,
+			cursor.objectType.Version,
+			cursor.objectType.Number,
+			cursor.domain)
+		cursor.err = object.scan(row, false, true)
+		if cursor.err == sql.ErrNoRows {
+			cursor.err = errors.New(string(MAL_ERROR_UNKNOWN_MESSAGE))
+		}
+	}
+	if cursor.err != nil {
+		return false
+	}
+	cursor.count++
+
+	cursor.archiveDetails, cursor.element, cursor.err = object.decode(true)
+	return cursor.err == nil
+}
+
+// Object returns the current object
+func (cursor *sqlRetrieveCursor) Object() (*ArchiveDetails, Element) {
+	return cursor.archiveDetails, cursor.element
+}
+
+// Err returns the error which stopped the cursor
+func (cursor *sqlRetrieveCursor) Err() error {
+	return cursor.err
+}
+
+// Close ends the transaction of the cursor, nothing has been written
+func (cursor *sqlRetrieveCursor) Close() error {
+	if cursor.rows != nil {
+		cursor.rows.Close()
+	}
+	return cursor.tx.Rollback()
+}
+
+//======================================================================//
+//                              DELETE                                  //
+//======================================================================//
+
+// sqlDeletedCursor iterates over the objects deleted by the Delete
+// operation, their rows have been read in the transaction of the delete
+type sqlDeletedCursor struct {
+	objects        []sqlObject
+	index          int
+	archiveDetails *ArchiveDetails
+	element        Element
+	err            error
+}
+
+// Next decodes the next deleted object
+func (cursor *sqlDeletedCursor) Next() bool {
+	if cursor.err != nil || cursor.index >= len(cursor.objects) {
+		return false
+	}
+	object := &cursor.objects[cursor.index]
+	cursor.index++
+
+	cursor.archiveDetails, cursor.element, cursor.err = object.decode(true)
+	return cursor.err == nil
+}
+
+// Object returns the current object
+func (cursor *sqlDeletedCursor) Object() (*ArchiveDetails, Element) {
+	return cursor.archiveDetails, cursor.element
+}
+
+// Err returns the error which stopped the cursor
+func (cursor *sqlDeletedCursor) Err() error {
+	return cursor.err
+}
+
+// Close does nothing, the transaction of the delete is already ended
+func (cursor *sqlDeletedCursor) Close() error {
+	return nil
+}
+
+//======================================================================//
+//                              QUERY                                   //
+//======================================================================//
+
+// sqlQueryCursor reads the rows of the Query operation, which are sorted
+// by group. The first object of the next group is read in advance to
+// know where the current group ends
+type sqlQueryCursor struct {
+	tx                 *sql.Tx
+	rows               *sql.Rows
+	isElementRequested bool
+	isDomainGrouped    bool
+	// queryDomain is the domain of the archive query when it contains
+	// wildcard identifiers, the domains of the rows are checked with it
+	queryDomain    *IdentifierList
+	bodyFilters    []*CompositeFilter
+	isStarted      bool
+	isEmpty        bool
+	group          archiveGroup
+	pending        *sqlObject
+	archiveDetails *ArchiveDetails
+	element        Element
+	err            error
+}
+
+// read reads the next row which matches the query domain and the filters
+// on the body of the objects, it returns nil at the end of the rows
+func (cursor *sqlQueryCursor) read() *sqlObject {
+	for cursor.rows.Next() {
+		var object = new(sqlObject)
+		cursor.err = object.scan(cursor.rows, true, cursor.isElementRequested || len(cursor.bodyFilters) > 0)
+		if cursor.err != nil {
+			return nil
+		}
+		if cursor.queryDomain != nil && !MatchDomain(*cursor.queryDomain, object.domain) {
+			continue
+		}
+		if len(cursor.bodyFilters) == 0 {
+			return object
+		}
+
+		cursor.err = object.decodeElement()
+		if cursor.err != nil {
+			return nil
+		}
+		isMatching, err := matchBodyFilters(object.element, cursor.bodyFilters)
+		if err != nil {
+			cursor.err = err
+			return nil
+		}
+		if isMatching {
+			return object
+		}
+	}
+	cursor.err = cursor.rows.Err()
+	return nil
+}
+
+// NextGroup moves the cursor to the group of the next row
+func (cursor *sqlQueryCursor) NextGroup() bool {
+	if cursor.err != nil {
+		return false
+	}
+	if !cursor.isStarted {
+		cursor.isStarted = true
+		cursor.pending = cursor.read()
+		if cursor.pending == nil {
+			// An empty result is a single empty group
+			cursor.isEmpty = true
+			return cursor.err == nil
+		}
+	} else {
+		// Skip the objects of the current group which haven't been read
+		for cursor.Next() {
+		}
+		if cursor.pending == nil {
+			return false
+		}
+	}
+	cursor.group = cursor.pending.group(cursor.isDomainGrouped)
+	return true
+}
+
+// Group returns the object type and the domain of the current group
+func (cursor *sqlQueryCursor) Group() (*ObjectType, *IdentifierList) {
+	if !cursor.isStarted || cursor.isEmpty {
+		return nil, nil
+	}
+	var objectType = &ObjectType{cursor.group.objectType.Area, cursor.group.objectType.Service, cursor.group.objectType.Version, cursor.group.objectType.Number}
+	var domain *IdentifierList
+	if cursor.isDomainGrouped {
+		idList := utils.AdaptDomainToIdentifierList(string(cursor.group.domain))
+		domain = &idList
+	}
+	return objectType, domain
+}
+
+// Next moves the cursor to the next object of the current group
+func (cursor *sqlQueryCursor) Next() bool {
+	if cursor.err != nil || cursor.pending == nil ||
+		cursor.pending.group(cursor.isDomainGrouped) != cursor.group {
+		return false
+	}
+
+	cursor.archiveDetails, cursor.element, cursor.err = cursor.pending.decode(cursor.isElementRequested)
+	if cursor.err != nil {
+		return false
+	}
+	cursor.pending = cursor.read()
+	return true
+}
+
+// Object returns the current object
+func (cursor *sqlQueryCursor) Object() (*ArchiveDetails, Element) {
+	return cursor.archiveDetails, cursor.element
+}
+
+// Err returns the error which stopped the cursor
+func (cursor *sqlQueryCursor) Err() error {
+	return cursor.err
+}
+
+// Close ends the transaction of the cursor, nothing has been written
+func (cursor *sqlQueryCursor) Close() error {
+	cursor.rows.Close()
+	return cursor.tx.Rollback()
+}
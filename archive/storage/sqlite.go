@@ -0,0 +1,100 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package storage
+
+import (
+	"database/sql"
+
+	// Init sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteDialect holds the specificities of SQLite
+type sqliteDialect struct {
+	path string
+}
+
+// NewSQLiteBackend creates a new backend which stores the objects of
+// the archive in an embedded SQLite database (the file is config.Path).
+// The database file is created, with its table, if it doesn't exist yet
+func NewSQLiteBackend(config Config) (*SQLBackend, error) {
+	return newSQLBackend(sqliteDialect{config.Path}, config)
+}
+
+func (sqliteDialect) driverName() string {
+	return "sqlite3"
+}
+
+func (dialect sqliteDialect) dataSourceName() string {
+	// Wait for the lock instead of failing when two operations
+	// write in the database at the same time
+	return "file:" + dialect.path + "?_busy_timeout=5000"
+}
+
+// rebind returns the query as it is, SQLite understands the '?'
+// placeholders and the backquoted columns
+func (sqliteDialect) rebind(query string) string {
+	return query
+}
+
+// contains searches the string with INSTR, which is case sensitive
+// (unlike LIKE) and doesn't give a meaning to '%' and '_'
+func (sqliteDialect) contains(column string, isCaseSensitive bool) string {
+	if isCaseSensitive {
+		return "INSTR(" + column + ", ?) > 0"
+	}
+	return "INSTR(LOWER(" + column + "), LOWER(?)) > 0"
+}
+
+// resetAutoIncrement sets the AUTOINCREMENT sequence of the table
+// to the maximum id of the table
+func (sqliteDialect) resetAutoIncrement(tx *sql.Tx, table string) error {
+	_, err := tx.Exec("UPDATE sqlite_sequence SET seq = (SELECT IFNULL(MAX(id), 0) FROM "+table+") WHERE name = ?", table)
+	return err
+}
+
+// migrations creates the same table than the other SQL backends
+func (sqliteDialect) migrations(table string) []migration {
+	return []migration{
+		{1, "create the table of the archive", []string{
+			"CREATE TABLE IF NOT EXISTS " + table + ` (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	objectInstanceIdentifier BIGINT,
+	element BLOB,
+	area SMALLINT,
+	service SMALLINT,
+	version TINYINT,
+	number SMALLINT,
+	domain TEXT,
+	timestamp DATETIME,
+	` + "`details.related`" + ` BIGINT,
+	network TEXT,
+	provider TEXT,
+	` + "`details.source`" + ` BLOB
+)`,
+		}, nil},
+		{2, "index the object type, domain, instance identifier and timestamp", indexStatements(table), nil},
+		{3, "make the object instance identifiers unique in each object type and domain", uniqueObjectStatements(table), duplicateObjectsCheck(table)},
+	}
+}
@@ -26,6 +26,7 @@ package utils
 import (
 	"bytes"
 	"strings"
+	"sync"
 
 	. "github.com/ccsdsmo/malgo/com"
 	. "github.com/ccsdsmo/malgo/mal"
@@ -45,6 +46,16 @@ func AdaptDomainToString(identifierList IdentifierList) String {
 	return domain
 }
 
+// HasNullIdentifier checks if a domain contains a NULL identifier
+func HasNullIdentifier(identifierList IdentifierList) bool {
+	for _, identifier := range identifierList {
+		if identifier == nil {
+			return true
+		}
+	}
+	return false
+}
+
 // AdaptDomainToIdentifierList transforms a domain of this
 // type: first.second.third.[...] to a list of Identifiers
 func AdaptDomainToIdentifierList(domain string) IdentifierList {
@@ -174,6 +185,67 @@ func ConvertToListShortForm(objectType ObjectType) Long {
 	return typeShort | 0x0000000FFFF00
 }
 
+// bodyTypes holds the short forms of the bodies of the object types whose
+// body isn't the element of the same number in their service, e.g. the
+// events of a service (see RegisterBodyType)
+var bodyTypes = struct {
+	sync.RWMutex
+	shortForms map[ObjectType]Long
+}{shortForms: make(map[ObjectType]Long)}
+
+// RegisterBodyType : Register the short form of the body of an object type,
+// when it isn't the element whose type short form is the number of the
+// object type. The ObjectStored event of the Archive Service (number 1) has
+// an ArchiveDetails body, as its ObjectUpdated event (number 2)
+func RegisterBodyType(objectType ObjectType, bodyShortForm Long) {
+	bodyTypes.Lock()
+	defer bodyTypes.Unlock()
+	bodyTypes.shortForms[objectType] = bodyShortForm
+}
+
+// NewElementList creates an empty list of elements of a given object type
+func NewElementList(objectType ObjectType) (ElementList, error) {
+	bodyTypes.RLock()
+	bodyShortForm, ok := bodyTypes.shortForms[objectType]
+	bodyTypes.RUnlock()
+	if ok {
+		return newElementListOf(bodyShortForm)
+	}
+
+	// Transform Type Short Form to List Short Form
+	listShortForm := ConvertToListShortForm(objectType)
+	// Get Element in the MAL Registry
+	element, err := LookupMALElement(listShortForm)
+	if err != nil {
+		return nil, err
+	}
+	return element.(ElementList).CreateElement().(ElementList), nil
+}
+
+// NewElementListFor creates an empty list which can hold an element of an
+// object type. The list is created from the short form of the element
+// itself, the body of an object isn't always the element of the same number
+// as its object type. A nil element gives the list of the object type
+func NewElementListFor(objectType ObjectType, element Element) (ElementList, error) {
+	if element == nil {
+		return NewElementList(objectType)
+	}
+	return newElementListOf(element.GetShortForm())
+}
+
+// newElementListOf creates an empty list of the elements of a short form,
+// the list short form has the same area, service and version and the
+// opposite type short form (on 24 bits)
+func newElementListOf(shortForm Long) (ElementList, error) {
+	var listShortForm = (shortForm &^ 0xFFFFFF) | (-(shortForm & 0xFFFFFF) & 0xFFFFFF)
+	// Get Element in the MAL Registry
+	element, err := LookupMALElement(listShortForm)
+	if err != nil {
+		return nil, err
+	}
+	return element.(ElementList).CreateElement().(ElementList), nil
+}
+
 func CheckCondition(cond *bool, buffer *bytes.Buffer) {
 	if *cond {
 		buffer.WriteString(" AND")
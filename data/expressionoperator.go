@@ -46,7 +46,10 @@ const (
 	COM_EXPRESSIONOPERATOR_SHORT_FORM      Long    = 0x2000201000005
 )
 
-// TransformOperator transforms an ExpressionOperator to a String
+// TransformOperator transforms an ExpressionOperator to the SQL operator
+// which compares a column with a value. CONTAINS and ICONTAINS are given as
+// LIKE: the value has to be a pattern matching any string around the
+// searched one, and both sides have to be lowered for ICONTAINS
 func (e ExpressionOperator) TransformOperator() String {
 	switch e {
 	case COM_EXPRESSIONOPERATOR_EQUAL:
@@ -62,9 +65,9 @@ func (e ExpressionOperator) TransformOperator() String {
 	case COM_EXPRESSIONOPERATOR_LESS_OR_EQUAL:
 		return "<="
 	case COM_EXPRESSIONOPERATOR_CONTAINS:
-		return "LIKE '%"
+		return "LIKE"
 	case COM_EXPRESSIONOPERATOR_ICONTAINS:
-		return "NOT LIKE '%"
+		return "LIKE"
 	default:
 		return ""
 	}
@@ -0,0 +1,66 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package event
+
+import (
+	"time"
+
+	. "github.com/ccsdsmo/malgo/mal"
+)
+
+// Constants for the Event Service
+const (
+	EVENT_SERVICE_SERVICE_IDENTIFIER = "Event"
+	EVENT_SERVICE_SERVICE_NUMBER     = 1
+)
+
+// Constants for the operations
+const (
+	OPERATION_IDENTIFIER_MONITOR_EVENT = 1
+)
+
+// Constants for the entity keys of the events
+const (
+	EVENT_SERVICE_ENTITY_KEY_WILDCARD = "*"
+)
+
+// Network of the events archived by the Event Service
+const (
+	EVENT_SERVICE_ARCHIVE_NETWORK = "events"
+)
+
+// Constants for the archive of the events
+const (
+	// EVENT_SERVICE_ARCHIVE_QUEUE_SIZE is the maximum number of groups of
+	// events waiting to be stored in the archive
+	EVENT_SERVICE_ARCHIVE_QUEUE_SIZE = 256
+	// EVENT_SERVICE_ARCHIVE_TIMEOUT bounds the Store operation of a group
+	EVENT_SERVICE_ARCHIVE_TIMEOUT = 10 * time.Second
+)
+
+// Constants for all the errors
+const (
+	EVENT_SERVICE_UNKNOWN_INTERACTION_STAGE_ERROR String = "Unknown interaction stage for the monitorEvent operation"
+	EVENT_SERVICE_PUBLISH_LISTS_SIZE_ERROR        String = "UpdateHeaderList, ObjectDetailsList and the list of bodies must have the same size"
+)
@@ -0,0 +1,205 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package consumer
+
+import (
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+	. "github.com/ccsdsmo/malgo/mal/api"
+	. "github.com/ccsdsmo/malgo/mal/encoding/binary"
+
+	. "github.com/etiennelndr/archiveservice/archive/constants"
+	. "github.com/etiennelndr/archiveservice/errors"
+	. "github.com/etiennelndr/archiveservice/event/constants"
+	"github.com/etiennelndr/archiveservice/event/utils"
+)
+
+// SubscriberConsumer : Consumer of the monitorEvent operation, it receives
+// the events matching its subscription
+type SubscriberConsumer struct {
+	ctx            *Context
+	cctx           *ClientContext
+	op             SubscriberOperation
+	factory        EncodingFactory
+	subscriptionId Identifier
+}
+
+// Close : Deregister the subscription and close the context of the consumer
+func (consumer *SubscriberConsumer) Close() {
+	// Create the encoder
+	encoder := consumer.factory.NewEncoder(make([]byte, 0, LENGTH))
+
+	// Encode IdentifierList
+	identifierList := IdentifierList([]*Identifier{&consumer.subscriptionId})
+	err := encoder.EncodeElement(&identifierList)
+	if err == nil {
+		consumer.op.Deregister(encoder.Body())
+	}
+
+	consumer.op.Close()
+	consumer.ctx.Close()
+}
+
+//======================================================================//
+//								CONSUMERS								//
+//======================================================================//
+// Create a consumer for a pubsub operation
+func createSubscriberConsumer(url string, providerURI *URI, typeOfConsumer string, subscriptionId Identifier) (*SubscriberConsumer, error) {
+	ctx, err := NewContext(url)
+	if err != nil {
+		return nil, err
+	}
+
+	cctx, err := NewClientContext(ctx, typeOfConsumer)
+	if err != nil {
+		return nil, err
+	}
+
+	op := cctx.NewSubscriberOperation(providerURI,
+		COM_AREA_NUMBER,
+		COM_AREA_VERSION,
+		EVENT_SERVICE_SERVICE_NUMBER,
+		OPERATION_IDENTIFIER_MONITOR_EVENT)
+
+	factory := new(FixedBinaryEncoding)
+
+	consumer := &SubscriberConsumer{ctx, cctx, op, factory, subscriptionId}
+
+	return consumer, nil
+}
+
+//======================================================================//
+//							MONITOR EVENT								//
+//======================================================================//
+// StartMonitorEventConsumer : Subscribe to the events of an object type (its
+// fields equal to 0 match any value) whose source is in a domain (which may
+// contain wildcard identifiers, a nil domain matches all the domains)
+func StartMonitorEventConsumer(url string, providerURI *URI, subscriptionId Identifier, objectType ObjectType, domain *IdentifierList) (*SubscriberConsumer, *ServiceError, error) {
+	// Create the consumer
+	consumer, err := createSubscriberConsumer(url, providerURI, "consumerMonitorEvent", subscriptionId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Call Register function
+	errorsList, err := consumer.monitorEventRegister(objectType, domain)
+	if err != nil {
+		// Close consumer
+		consumer.ctx.Close()
+		return nil, nil, err
+	} else if errorsList != nil {
+		// Close consumer
+		consumer.ctx.Close()
+		return nil, errorsList, nil
+	}
+
+	return consumer, nil, nil
+}
+
+// Register : Register the subscription of the consumer
+func (consumer *SubscriberConsumer) monitorEventRegister(objectType ObjectType, domain *IdentifierList) (*ServiceError, error) {
+	// Create the encoder
+	encoder := consumer.factory.NewEncoder(make([]byte, 0, LENGTH))
+
+	// Encode Subscription
+	entityKey := utils.NewSubscriptionKey(objectType)
+	subscription := &Subscription{
+		SubscriptionId: consumer.subscriptionId,
+		Entities: EntityRequestList([]*EntityRequest{&EntityRequest{
+			SubDomain:     domain,
+			AllAreas:      true,
+			AllServices:   true,
+			AllOperations: true,
+			OnlyOnChange:  false,
+			EntityKeys:    EntityKeyList([]*EntityKey{&entityKey}),
+		}}),
+	}
+	err := encoder.EncodeElement(subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call Register operation
+	resp, err := consumer.op.Register(encoder.Body())
+	if err != nil {
+		// Verify if an error occurs during the operation
+		if resp != nil && resp.IsErrorMessage {
+			// Create the decoder
+			decoder := consumer.factory.NewDecoder(resp.Body)
+			// Decode the error
+			errorsList, err := DecodeError(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			return errorsList, nil
+		}
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// GetEvents : Wait for the next notification of the provider and return its
+// events: their headers, their details (the source of an event is the object
+// which raised it) and their bodies (nil for events without a body)
+func (consumer *SubscriberConsumer) GetEvents() (*UpdateHeaderList, *ObjectDetailsList, ElementList, error) {
+	// Call GetNotify operation
+	resp, err := consumer.op.GetNotify()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Create the decoder
+	decoder := consumer.factory.NewDecoder(resp.Body)
+
+	// Decode Identifier
+	_, err = decoder.DecodeElement(NullIdentifier)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Decode UpdateHeaderList
+	updateHeaderList, err := decoder.DecodeElement(NullUpdateHeaderList)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Decode ObjectDetailsList
+	objectDetailsList, err := decoder.DecodeElement(NullObjectDetailsList)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Decode the list of bodies
+	bodyList, err := decoder.DecodeNullableAbstractElement()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if bodyList == nil || bodyList.IsNull() {
+		return updateHeaderList.(*UpdateHeaderList), objectDetailsList.(*ObjectDetailsList), nil, nil
+	}
+	return updateHeaderList.(*UpdateHeaderList), objectDetailsList.(*ObjectDetailsList), bodyList.(ElementList), nil
+}
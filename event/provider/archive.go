@@ -0,0 +1,238 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+
+	. "github.com/etiennelndr/archiveservice/archive/consumer"
+	archutils "github.com/etiennelndr/archiveservice/archive/utils"
+	. "github.com/etiennelndr/archiveservice/data"
+	. "github.com/etiennelndr/archiveservice/event/constants"
+	"github.com/etiennelndr/archiveservice/event/utils"
+)
+
+// eventArchive stores the published events with the Store operation of
+// an Archive Service provider. The groups of events are queued and stored
+// by a goroutine, so the publication of the events never waits for the
+// archive. A group which doesn't fit in the queue, or which can't be
+// stored in time, is dropped and its events are counted
+type eventArchive struct {
+	consumerURL string
+	providerURI *URI
+	groups      chan *eventGroup
+	dropped     *uint64
+}
+
+// eventGroup holds the events of the same object type and domain, they
+// are stored with a single Store operation
+type eventGroup struct {
+	objectType         ObjectType
+	domain             IdentifierList
+	archiveDetailsList ArchiveDetailsList
+	bodyList           ElementList
+}
+
+// SetArchive : Allow the provider to store each published event in the archive
+// of an Archive Service provider, consumerURL is the URL of the consumer used
+// for the Store operation. The events are stored in the background, at most
+// EVENT_SERVICE_ARCHIVE_QUEUE_SIZE groups of events wait to be stored and each
+// Store operation has EVENT_SERVICE_ARCHIVE_TIMEOUT to end (see DroppedEvents)
+func (provider *Provider) SetArchive(consumerURL string, archiveProviderURI *URI) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.archive != nil {
+		close(provider.archive.groups)
+	}
+	provider.archive = &eventArchive{
+		consumerURL: consumerURL,
+		providerURI: archiveProviderURI,
+		groups:      make(chan *eventGroup, EVENT_SERVICE_ARCHIVE_QUEUE_SIZE),
+		dropped:     &provider.droppedEvents,
+	}
+	go provider.archive.run()
+}
+
+// DroppedEvents : Return the number of events which haven't been stored in
+// the archive, because too many events were waiting to be stored or because
+// the Store operation failed
+func (provider *Provider) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&provider.droppedEvents)
+}
+
+// stopArchive stops storing the events, when the provider is closed. The
+// events already queued are still stored
+func (provider *Provider) stopArchive() {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.archive != nil {
+		close(provider.archive.groups)
+		provider.archive = nil
+	}
+}
+
+// archiveEvents queues the events to store in the archive, when it is set. An
+// event is stored with its object type (from its entity key), the domain of
+// its source (or the domain of the Publish message for an event without a
+// source) and its body. The events without a body or a domain aren't stored,
+// nor are the events raised by the archive for the storage of the events
+// themselves. The archive reads an event back with the type of its body,
+// which isn't the element of the same number as the event (see
+// utils.RegisterBodyType)
+func (provider *Provider) archiveEvents(msgDomain IdentifierList, updateHeaderList UpdateHeaderList, objectDetailsList ObjectDetailsList, bodyList ElementList) error {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	archive := provider.archive
+	if archive == nil || bodyList == nil {
+		return nil
+	}
+
+	var groups []*eventGroup
+	for i, updateHeader := range updateHeaderList {
+		body := bodyList.GetElementAt(i)
+		if isArchivedEvent(body) {
+			continue
+		}
+
+		eventType, err := utils.EventObjectType(updateHeader.Key)
+		if err != nil {
+			return err
+		}
+
+		var objectDetails = *objectDetailsList[i]
+		var instId Long
+		if updateHeader.Key.ThirdSubKey != nil {
+			instId = *updateHeader.Key.ThirdSubKey
+		}
+		var domain IdentifierList
+		if objectDetails.Source != nil && objectDetails.Source.Key != nil {
+			domain = objectDetails.Source.Key.Domain
+		} else {
+			// The archive needs a source, the event is its own source
+			domain = msgDomain
+			objectDetails.Source = &ObjectId{
+				Type: &eventType,
+				Key:  &ObjectKey{Domain: domain, InstId: instId},
+			}
+		}
+		if len(domain) == 0 {
+			// The Store operation needs a domain
+			continue
+		}
+
+		var group *eventGroup
+		for _, g := range groups {
+			if g.objectType == eventType && archutils.AdaptDomainToString(g.domain) == archutils.AdaptDomainToString(domain) {
+				group = g
+				break
+			}
+		}
+		if group == nil {
+			group = &eventGroup{
+				objectType: eventType,
+				domain:     domain,
+				bodyList:   bodyList.CreateElement().(ElementList),
+			}
+			groups = append(groups, group)
+		}
+
+		var network = Identifier(EVENT_SERVICE_ARCHIVE_NETWORK)
+		var timestamp = FineTime(updateHeader.Timestamp)
+		var sourceURI = updateHeader.SourceURI
+		group.archiveDetailsList.AppendElement(NewArchiveDetails(instId, objectDetails, &network, &timestamp, &sourceURI))
+		group.bodyList.AppendElement(body)
+	}
+
+	for _, group := range groups {
+		select {
+		case archive.groups <- group:
+		default:
+			// Too many events wait to be stored, the publication doesn't wait
+			atomic.AddUint64(archive.dropped, uint64(group.archiveDetailsList.Size()))
+		}
+	}
+
+	return nil
+}
+
+// run stores the queued groups of events until the archive is stopped. The
+// same client (and MAL context) is used for all the Store operations
+func (archive *eventArchive) run() {
+	var client *ArchiveClient
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+	}()
+
+	for group := range archive.groups {
+		var err error
+		if client == nil {
+			client, err = NewArchiveClient(archive.consumerURL, archive.providerURI)
+		}
+		if err == nil {
+			err = archive.store(client, group)
+		}
+		if err != nil {
+			atomic.AddUint64(archive.dropped, uint64(group.archiveDetailsList.Size()))
+		}
+	}
+}
+
+// store stores a group of events, the Store operation is aborted once
+// EVENT_SERVICE_ARCHIVE_TIMEOUT has elapsed
+func (archive *eventArchive) store(client *ArchiveClient, group *eventGroup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), EVENT_SERVICE_ARCHIVE_TIMEOUT)
+	defer cancel()
+
+	_, errorsList, err := client.Store(ctx,
+		NewBoolean(false),
+		group.objectType,
+		group.domain,
+		group.archiveDetailsList,
+		group.bodyList)
+	if err != nil {
+		return err
+	} else if errorsList != nil {
+		return errors.New(string(*errorsList.ErrorComment))
+	}
+	return nil
+}
+
+// isArchivedEvent checks if the body of an event is the ArchiveDetails of an
+// archived event, i.e. the event is raised by the archive for the storage of
+// another event. It isn't stored, or each event would raise a new one
+func isArchivedEvent(body Element) bool {
+	archiveDetails, ok := body.(*ArchiveDetails)
+	return ok && archiveDetails != nil && archiveDetails.Network != nil &&
+		*archiveDetails.Network == EVENT_SERVICE_ARCHIVE_NETWORK
+}
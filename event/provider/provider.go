@@ -0,0 +1,370 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package provider
+
+import (
+	"errors"
+	"sync"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+	. "github.com/ccsdsmo/malgo/mal/api"
+	. "github.com/ccsdsmo/malgo/mal/encoding/binary"
+
+	. "github.com/etiennelndr/archiveservice/archive/constants"
+	arch "github.com/etiennelndr/archiveservice/archive/storage"
+	archutils "github.com/etiennelndr/archiveservice/archive/utils"
+	. "github.com/etiennelndr/archiveservice/errors"
+	. "github.com/etiennelndr/archiveservice/event/constants"
+	"github.com/etiennelndr/archiveservice/event/utils"
+)
+
+// Define Provider's structure, the provider of the Event Service is the
+// broker of the monitorEvent operation: it forwards the published events
+// to the consumers which subscribed to them
+type Provider struct {
+	ctx     *Context
+	cctx    *ClientContext
+	factory EncodingFactory
+
+	mutex       sync.Mutex
+	subscribers map[subscriberKey]*subscriber
+	archive     *eventArchive
+	// droppedEvents counts the events which haven't been archived, it is
+	// used atomically
+	droppedEvents uint64
+}
+
+// subscriberKey identifies a subscription of a consumer
+type subscriberKey struct {
+	uri            URI
+	subscriptionId Identifier
+}
+
+// subscriber holds a subscription and the transaction used to notify the
+// consumer of the events
+type subscriber struct {
+	transaction SubscriberTransaction
+	entities    EntityRequestList
+}
+
+// Create a provider
+func createProvider(url string) (*Provider, error) {
+	ctx, err := NewContext(url)
+	if err != nil {
+		return nil, err
+	}
+
+	cctx, err := NewClientContext(ctx, "eventServiceProvider")
+	if err != nil {
+		return nil, err
+	}
+
+	factory := new(FixedBinaryEncoding)
+
+	provider := &Provider{
+		ctx:         ctx,
+		cctx:        cctx,
+		factory:     factory,
+		subscribers: make(map[subscriberKey]*subscriber),
+	}
+
+	return provider, nil
+}
+
+// StartProvider : Start the provider of the Event Service
+func StartProvider(url string) (*Provider, error) {
+	// Create the provider
+	provider, err := createProvider(url)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create and launch the MonitorEvent handler
+	err = provider.monitorEventHandler()
+	if err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+// Close : Allow to close the context of a specific provider
+func (provider *Provider) Close() {
+	provider.stopArchive()
+	provider.ctx.Close()
+}
+
+//======================================================================//
+//							MONITOR EVENT								//
+//======================================================================//
+// Create a handler for the monitorEvent operation
+func (provider *Provider) monitorEventHandler() error {
+	monitorEventHandler := func(msg *Message, t Transaction) error {
+		if msg != nil {
+			switch msg.InteractionStage {
+			case MAL_IP_STAGE_PUBSUB_REGISTER:
+				return provider.register(msg, t.(SubscriberTransaction))
+			case MAL_IP_STAGE_PUBSUB_DEREGISTER:
+				return provider.deregister(msg, t.(SubscriberTransaction))
+			case MAL_IP_STAGE_PUBSUB_PUBLISH_REGISTER:
+				return t.(PublisherTransaction).AckRegister(nil, false)
+			case MAL_IP_STAGE_PUBSUB_PUBLISH_DEREGISTER:
+				return t.(PublisherTransaction).AckDeregister(nil, false)
+			case MAL_IP_STAGE_PUBSUB_PUBLISH:
+				return provider.publish(msg, t.(PublisherTransaction))
+			default:
+				return errors.New(string(EVENT_SERVICE_UNKNOWN_INTERACTION_STAGE_ERROR))
+			}
+		}
+
+		return nil
+	}
+
+	// Register the handler
+	err := provider.cctx.RegisterBrokerHandler(COM_AREA_NUMBER,
+		COM_AREA_VERSION,
+		EVENT_SERVICE_SERVICE_NUMBER,
+		OPERATION_IDENTIFIER_MONITOR_EVENT,
+		monitorEventHandler)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// REGISTER : Register (or replace) the subscription of a consumer
+func (provider *Provider) register(msg *Message, transaction SubscriberTransaction) error {
+	// Create the decoder
+	decoder := provider.factory.NewDecoder(msg.Body)
+
+	// Decode Subscription
+	element, err := decoder.DecodeElement(NullSubscription)
+	if err != nil {
+		provider.registerError(transaction, MAL_ERROR_BAD_ENCODING, MAL_ERROR_BAD_ENCODING_MESSAGE)
+		return err
+	}
+	subscription := element.(*Subscription)
+
+	var uri URI
+	if msg.UriFrom != nil {
+		uri = *msg.UriFrom
+	}
+
+	provider.mutex.Lock()
+	provider.subscribers[subscriberKey{uri, subscription.SubscriptionId}] = &subscriber{transaction, subscription.Entities}
+	provider.mutex.Unlock()
+
+	return transaction.AckRegister(nil, false)
+}
+
+// REGISTER ERROR : Reply to the Register operation with an error
+func (provider *Provider) registerError(transaction SubscriberTransaction, errorNumber UInteger, errorComment String) error {
+	// Create the encoder
+	encoder := provider.factory.NewEncoder(make([]byte, 0, LENGTH))
+
+	encoder, err := EncodeError(encoder, errorNumber, errorComment, NewLong(0))
+	if err != nil {
+		return err
+	}
+
+	return transaction.AckRegister(encoder.Body(), true)
+}
+
+// DEREGISTER : Remove the subscriptions of a consumer
+func (provider *Provider) deregister(msg *Message, transaction SubscriberTransaction) error {
+	// Create the decoder
+	decoder := provider.factory.NewDecoder(msg.Body)
+
+	// Decode IdentifierList
+	element, err := decoder.DecodeElement(NullIdentifierList)
+	if err != nil {
+		return err
+	}
+	identifierList := element.(*IdentifierList)
+
+	var uri URI
+	if msg.UriFrom != nil {
+		uri = *msg.UriFrom
+	}
+
+	provider.mutex.Lock()
+	for _, subscriptionId := range *identifierList {
+		delete(provider.subscribers, subscriberKey{uri, *subscriptionId})
+	}
+	provider.mutex.Unlock()
+
+	return transaction.AckDeregister(nil, false)
+}
+
+// PUBLISH : Notify the consumers of the published events and archive them
+func (provider *Provider) publish(msg *Message, transaction PublisherTransaction) error {
+	updateHeaderList, objectDetailsList, bodyList, err := provider.publishDecode(msg)
+	if err != nil {
+		provider.publishError(transaction, MAL_ERROR_BAD_ENCODING, MAL_ERROR_BAD_ENCODING_MESSAGE)
+		return err
+	}
+
+	if updateHeaderList.Size() != objectDetailsList.Size() || (bodyList != nil && bodyList.Size() != updateHeaderList.Size()) {
+		provider.publishError(transaction, COM_ERROR_INVALID, EVENT_SERVICE_PUBLISH_LISTS_SIZE_ERROR)
+		return errors.New(string(EVENT_SERVICE_PUBLISH_LISTS_SIZE_ERROR))
+	}
+
+	// Notify the consumers
+	err = provider.notify(*updateHeaderList, *objectDetailsList, bodyList)
+	if err != nil {
+		return err
+	}
+
+	// Archive the events
+	return provider.archiveEvents(msg.Domain, *updateHeaderList, *objectDetailsList, bodyList)
+}
+
+// PUBLISH DECODE : Decode the body of a Publish message
+func (provider *Provider) publishDecode(msg *Message) (*UpdateHeaderList, *ObjectDetailsList, ElementList, error) {
+	// Create the decoder
+	decoder := provider.factory.NewDecoder(msg.Body)
+
+	// Decode UpdateHeaderList
+	updateHeaderList, err := decoder.DecodeElement(NullUpdateHeaderList)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Decode ObjectDetailsList
+	objectDetailsList, err := decoder.DecodeElement(NullObjectDetailsList)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Decode the list of bodies
+	bodyList, err := decoder.DecodeNullableAbstractElement()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if bodyList == nil || bodyList.IsNull() {
+		return updateHeaderList.(*UpdateHeaderList), objectDetailsList.(*ObjectDetailsList), nil, nil
+	}
+	return updateHeaderList.(*UpdateHeaderList), objectDetailsList.(*ObjectDetailsList), bodyList.(ElementList), nil
+}
+
+// PUBLISH ERROR : Inform the publisher of an error
+func (provider *Provider) publishError(transaction PublisherTransaction, errorNumber UInteger, errorComment String) error {
+	// Create the encoder
+	encoder := provider.factory.NewEncoder(make([]byte, 0, LENGTH))
+
+	encoder, err := EncodeError(encoder, errorNumber, errorComment, NewLong(0))
+	if err != nil {
+		return err
+	}
+
+	return transaction.PublishError(encoder.Body(), true)
+}
+
+// NOTIFY : Send to each consumer the events matching its subscription
+func (provider *Provider) notify(updateHeaderList UpdateHeaderList, objectDetailsList ObjectDetailsList, bodyList ElementList) error {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	for key, subscriber := range provider.subscribers {
+		var notifiedHeaderList = NewUpdateHeaderList(0)
+		var notifiedDetailsList = NewObjectDetailsList(0)
+		var notifiedBodyList ElementList
+		if bodyList != nil {
+			notifiedBodyList = bodyList.CreateElement().(ElementList)
+		}
+
+		for i, updateHeader := range updateHeaderList {
+			if !subscriber.matches(*updateHeader, *objectDetailsList[i]) {
+				continue
+			}
+			notifiedHeaderList.AppendElement(updateHeader)
+			notifiedDetailsList.AppendElement(objectDetailsList[i])
+			if bodyList != nil {
+				notifiedBodyList.AppendElement(bodyList.GetElementAt(i))
+			}
+		}
+		if notifiedHeaderList.Size() == 0 {
+			continue
+		}
+
+		// Create the encoder
+		encoder := provider.factory.NewEncoder(make([]byte, 0, LENGTH))
+
+		// Encode Identifier
+		err := encoder.EncodeElement(&key.subscriptionId)
+		if err != nil {
+			return err
+		}
+
+		// Encode UpdateHeaderList
+		err = encoder.EncodeElement(notifiedHeaderList)
+		if err != nil {
+			return err
+		}
+
+		// Encode ObjectDetailsList
+		err = encoder.EncodeElement(notifiedDetailsList)
+		if err != nil {
+			return err
+		}
+
+		// Encode the list of bodies
+		err = encoder.EncodeNullableAbstractElement(notifiedBodyList)
+		if err != nil {
+			return err
+		}
+
+		// The consumer is removed when it can't be notified anymore
+		err = subscriber.transaction.Notify(encoder.Body(), false)
+		if err != nil {
+			delete(provider.subscribers, key)
+		}
+	}
+
+	return nil
+}
+
+// matches checks if an event matches one of the entity requests of a
+// subscription, with its domain (the domain of its source) and its key
+func (subscriber *subscriber) matches(updateHeader UpdateHeader, objectDetails ObjectDetails) bool {
+	var domain IdentifierList
+	if objectDetails.Source != nil && objectDetails.Source.Key != nil {
+		domain = objectDetails.Source.Key.Domain
+	}
+
+	for _, entityRequest := range subscriber.entities {
+		if entityRequest.SubDomain != nil && !arch.MatchDomain(*entityRequest.SubDomain, archutils.AdaptDomainToString(domain)) {
+			continue
+		}
+		for _, entityKey := range entityRequest.EntityKeys {
+			if utils.MatchEntityKey(*entityKey, updateHeader.Key) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package provider
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+	. "github.com/ccsdsmo/malgo/mal/api"
+	. "github.com/ccsdsmo/malgo/mal/encoding/binary"
+
+	. "github.com/etiennelndr/archiveservice/archive/constants"
+	. "github.com/etiennelndr/archiveservice/event/constants"
+	"github.com/etiennelndr/archiveservice/event/utils"
+)
+
+// Publisher publishes events with the monitorEvent operation, it is used by
+// the services which raise events (e.g. the Archive Service) to send them to
+// the Event Service provider
+type Publisher struct {
+	mutex     sync.Mutex
+	op        PublisherOperation
+	factory   EncodingFactory
+	sourceURI URI
+}
+
+// NewPublisher creates a publisher of events with the client context of a
+// service and registers it to the Event Service provider
+func NewPublisher(cctx *ClientContext, providerURI *URI) (*Publisher, error) {
+	op := cctx.NewPublisherOperation(providerURI,
+		COM_AREA_NUMBER,
+		COM_AREA_VERSION,
+		EVENT_SERVICE_SERVICE_NUMBER,
+		OPERATION_IDENTIFIER_MONITOR_EVENT)
+
+	factory := new(FixedBinaryEncoding)
+
+	// Create the encoder
+	encoder := factory.NewEncoder(make([]byte, 0, LENGTH))
+
+	// Encode EntityKeyList, the events are published with any key
+	entityKeyList := EntityKeyList([]*EntityKey{&EntityKey{
+		FirstSubKey:  NewIdentifier(EVENT_SERVICE_ENTITY_KEY_WILDCARD),
+		SecondSubKey: NewLong(0),
+		ThirdSubKey:  NewLong(0),
+		FourthSubKey: NewLong(0),
+	}})
+	err := encoder.EncodeElement(&entityKeyList)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call Register operation
+	_, err = op.Register(encoder.Body())
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceURI URI
+	if cctx.Uri != nil {
+		sourceURI = *cctx.Uri
+	}
+
+	publisher := &Publisher{op: op, factory: factory, sourceURI: sourceURI}
+
+	return publisher, nil
+}
+
+// Publish publishes an event of a given object type for each element of the
+// ObjectDetailsList. The source of the details is the object which raised the
+// event, the domain of its ObjectId is the domain of the event. The list of
+// bodies holds the body of each event, it is nil for events without a body
+func (publisher *Publisher) Publish(eventType ObjectType, updateType UpdateType, objectDetailsList ObjectDetailsList, bodyList ElementList) error {
+	if bodyList != nil && bodyList.Size() != objectDetailsList.Size() {
+		return errors.New(string(EVENT_SERVICE_PUBLISH_LISTS_SIZE_ERROR))
+	}
+
+	var timestamp = Time(time.Now())
+	var updateHeaderList = NewUpdateHeaderList(0)
+	for _, objectDetails := range objectDetailsList {
+		var sourceType *ObjectType
+		if objectDetails.Source != nil {
+			sourceType = objectDetails.Source.Type
+		}
+		updateHeaderList.AppendElement(&UpdateHeader{
+			Timestamp:  timestamp,
+			SourceURI:  publisher.sourceURI,
+			UpdateType: updateType,
+			Key:        utils.NewEventKey(eventType, 0, sourceType),
+		})
+	}
+
+	// Create the encoder
+	encoder := publisher.factory.NewEncoder(make([]byte, 0, LENGTH))
+
+	// Encode UpdateHeaderList
+	err := encoder.EncodeElement(updateHeaderList)
+	if err != nil {
+		return err
+	}
+
+	// Encode ObjectDetailsList
+	err = encoder.EncodeElement(&objectDetailsList)
+	if err != nil {
+		return err
+	}
+
+	// Encode the list of bodies
+	err = encoder.EncodeNullableAbstractElement(bodyList)
+	if err != nil {
+		return err
+	}
+
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	// Call Publish operation
+	return publisher.op.Publish(encoder.Body())
+}
+
+// Close deregisters the publisher from the Event Service provider
+func (publisher *Publisher) Close() error {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	_, err := publisher.op.Deregister([]byte{})
+	if err != nil {
+		publisher.op.Close()
+		return err
+	}
+
+	return publisher.op.Close()
+}
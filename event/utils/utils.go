@@ -0,0 +1,135 @@
+/**
+ * MIT License
+ *
+ * Copyright (c) 2018 CNES
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package utils
+
+import (
+	"strconv"
+
+	. "github.com/ccsdsmo/malgo/com"
+	. "github.com/ccsdsmo/malgo/mal"
+
+	. "github.com/etiennelndr/archiveservice/event/constants"
+)
+
+// ObjectTypeToSubKey transforms an object type to a sub-key of an entity
+// key: area (16 bits), service (16 bits), version (8 bits) and number (24 bits)
+func ObjectTypeToSubKey(objectType ObjectType) Long {
+	return Long(objectType.Area)<<48 | Long(objectType.Service)<<32 | Long(objectType.Version)<<24 | Long(objectType.Number)
+}
+
+// SubKeyToObjectType transforms a sub-key of an entity key to an object type
+func SubKeyToObjectType(subKey Long) ObjectType {
+	return ObjectType{
+		Area:    UShort(subKey >> 48),
+		Service: UShort(subKey >> 32),
+		Version: UOctet(subKey >> 24),
+		Number:  UShort(subKey & 0xFFFFFF),
+	}
+}
+
+// NewEventKey creates the entity key of an event: the number of the event,
+// the area, service and version of the event, the object instance identifier
+// of the event and the object type of its source
+func NewEventKey(eventType ObjectType, eventInstId Long, sourceType *ObjectType) EntityKey {
+	var serviceType = eventType
+	serviceType.Number = 0
+	var sourceSubKey Long
+	if sourceType != nil {
+		sourceSubKey = ObjectTypeToSubKey(*sourceType)
+	}
+	return EntityKey{
+		FirstSubKey:  NewIdentifier(strconv.Itoa(int(eventType.Number))),
+		SecondSubKey: NewLong(int64(ObjectTypeToSubKey(serviceType))),
+		ThirdSubKey:  &eventInstId,
+		FourthSubKey: &sourceSubKey,
+	}
+}
+
+// NewSubscriptionKey creates the entity key which matches the events of an
+// object type. A field of the object type equal to 0 matches any value, e.g.
+// a number equal to 0 matches all the events of a service
+func NewSubscriptionKey(eventType ObjectType) EntityKey {
+	var firstSubKey = NewIdentifier(EVENT_SERVICE_ENTITY_KEY_WILDCARD)
+	if eventType.Number != 0 {
+		firstSubKey = NewIdentifier(strconv.Itoa(int(eventType.Number)))
+	}
+	var serviceType = eventType
+	serviceType.Number = 0
+	return EntityKey{
+		FirstSubKey:  firstSubKey,
+		SecondSubKey: NewLong(int64(ObjectTypeToSubKey(serviceType))),
+		ThirdSubKey:  NewLong(0),
+		FourthSubKey: NewLong(0),
+	}
+}
+
+// EventObjectType returns the object type of an event from its entity key
+func EventObjectType(eventKey EntityKey) (ObjectType, error) {
+	var objectType ObjectType
+	if eventKey.SecondSubKey != nil {
+		objectType = SubKeyToObjectType(*eventKey.SecondSubKey)
+	}
+	if eventKey.FirstSubKey == nil {
+		return objectType, nil
+	}
+	number, err := strconv.Atoi(string(*eventKey.FirstSubKey))
+	if err != nil {
+		return objectType, err
+	}
+	objectType.Number = UShort(number)
+	return objectType, nil
+}
+
+// MatchEntityKey checks if the entity key of an event matches the entity key
+// of a subscription. The '*' first sub-key and the other sub-keys equal to 0
+// (or the fields equal to 0 of the object types) match any value
+func MatchEntityKey(subscriptionKey EntityKey, eventKey EntityKey) bool {
+	if subscriptionKey.FirstSubKey != nil && *subscriptionKey.FirstSubKey != EVENT_SERVICE_ENTITY_KEY_WILDCARD &&
+		(eventKey.FirstSubKey == nil || *subscriptionKey.FirstSubKey != *eventKey.FirstSubKey) {
+		return false
+	}
+	if subscriptionKey.ThirdSubKey != nil && *subscriptionKey.ThirdSubKey != 0 &&
+		(eventKey.ThirdSubKey == nil || *subscriptionKey.ThirdSubKey != *eventKey.ThirdSubKey) {
+		return false
+	}
+	return matchObjectTypeSubKey(subscriptionKey.SecondSubKey, eventKey.SecondSubKey) &&
+		matchObjectTypeSubKey(subscriptionKey.FourthSubKey, eventKey.FourthSubKey)
+}
+
+// matchObjectTypeSubKey checks if a sub-key holding an object type matches
+// the sub-key of a subscription, field by field
+func matchObjectTypeSubKey(subscriptionSubKey *Long, eventSubKey *Long) bool {
+	if subscriptionSubKey == nil || *subscriptionSubKey == 0 {
+		return true
+	}
+	if eventSubKey == nil {
+		return false
+	}
+	var subscriptionType = SubKeyToObjectType(*subscriptionSubKey)
+	var eventType = SubKeyToObjectType(*eventSubKey)
+	return (subscriptionType.Area == 0 || subscriptionType.Area == eventType.Area) &&
+		(subscriptionType.Service == 0 || subscriptionType.Service == eventType.Service) &&
+		(subscriptionType.Version == 0 || subscriptionType.Version == eventType.Version) &&
+		(subscriptionType.Number == 0 || subscriptionType.Number == eventType.Number)
+}
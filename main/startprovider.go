@@ -24,20 +24,37 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
 
-	. "github.com/ccsdsmo/malgo/com"
 	. "github.com/ccsdsmo/malgo/mal"
 
+	. "github.com/etiennelndr/archiveservice/archive/constants"
 	. "github.com/etiennelndr/archiveservice/archive/service"
+	arch "github.com/etiennelndr/archiveservice/archive/storage"
+	evt "github.com/etiennelndr/archiveservice/event/provider"
 )
 
 // Constants for the providers and consumers
 const (
-	providerURL = "maltcp://127.0.0.1:12400"
+	providerURL      = "maltcp://127.0.0.1:12400"
+	eventProviderURL = "maltcp://127.0.0.1:12401"
+	eventConsumerURL = "maltcp://127.0.0.1:14201"
+)
+
+// Flags to choose the storage of the archive
+var (
+	configPath  = flag.String("config", os.Getenv(arch.ENV_CONFIG), "path of the JSON configuration file of the storage")
+	backendName = flag.String("backend", "", "storage of the archive (overrides the configuration): mysql, sqlite, postgres or memory")
+	brokerURL   = flag.String("broker", "", "URL of the broker to which the events of the archive are published (none by default)")
+	withEvents  = flag.Bool("events", false, "start an Event Service provider which receives and archives the events of the archive")
+	maxObjects  = flag.Int("maxobjects", DEFAULT_MAX_OBJECTS_PER_UPDATE, "maximum number of objects sent in each indexed update of the Query operation (0 means no limit)")
 )
 
 func main() {
+	flag.Parse()
+
 	// Variable that defines the ArchiveService
 	var archiveService *ArchiveService
 	// Variable to retrieve the error
@@ -45,10 +62,48 @@ func main() {
 	// Create the Archive Service
 	archiveService = archiveService.CreateService().(*ArchiveService)
 
+	// Create the backend
+	backend, err := createBackend()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	// Start the Event Service provider, the archive publishes its events to it
+	if *withEvents {
+		eventProvider, err := evt.StartProvider(eventProviderURL)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		defer eventProvider.Close()
+		eventProvider.SetArchive(eventConsumerURL, NewURI(providerURL+"/archiveServiceProvider"))
+		*brokerURL = eventProviderURL + "/eventServiceProvider"
+	}
+
+	// Publish the events of the archive
+	archiveService.SetEventBroker(*brokerURL)
+
+	// Split the big groups of objects of the queries
+	archiveService.SetMaxObjectsPerUpdate(*maxObjects)
+
 	// Start the providers
-	err = archiveService.StartProvider(providerURL)
+	err = archiveService.StartProvider(providerURL, backend)
 
 	if err != nil {
 		fmt.Println("Error:", err)
 	}
 }
+
+// createBackend creates the backend described by the configuration
+// file, the environment variables and the flags
+func createBackend() (arch.ArchiveBackend, error) {
+	config, err := arch.LoadConfig(*configPath)
+	if err != nil {
+		return nil, err
+	}
+	if *backendName != "" {
+		config.Backend = *backendName
+	}
+	return arch.NewBackend(config)
+}
@@ -23,8 +23,12 @@
  */
 package service
 
+import (
+	arch "github.com/etiennelndr/archiveservice/archive/storage"
+)
+
 type Service interface {
 	CreateService() Service
 
-	StartProvider(providerURL string) error
+	StartProvider(providerURL string, backend arch.ArchiveBackend) error
 }